@@ -22,64 +22,117 @@ type Options struct {
 }
 
 var Backend = &proto.BackendImpl{
-	Backup: func(req *proto.BackupRequest) error {
+	Backup: func(req *proto.BackupRequest) (*proto.BackupResponse, error) {
 		var options Options
 		err := mapstructure.Decode(req.RawOptions, &options)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		exists, err := checkRepoExists(options.Repo, options.Env)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if !exists {
 			fmt.Fprintf(os.Stderr, "repo %s does not exist, creating it", options.Repo)
-			err := restic(options.Repo, options.Env, "init")
+			reportProgress(req, proto.ProgressEvent{Phase: "init"})
+			err := resticStderr(options.Repo, options.Env, "init")
 			if err != nil {
-				return fmt.Errorf("failed to initialize repository %s: %w",
+				return nil, fmt.Errorf("failed to initialize repository %s: %w",
 					options.Repo, err)
 			}
 		}
 
-		tags := []string{
-			fmt.Sprintf("sb:dest:%s", req.DestinationName),
-			fmt.Sprintf("sb:job:%s", req.JobName),
-		}
-		if req.VariantName != "" {
-			tags = append(tags, fmt.Sprintf("sb:variant:%s", req.VariantName))
-		}
-
-		backupArgs := []string{"backup"}
-		for _, exclude := range req.Exclude {
-			backupArgs = append(backupArgs, "--exclude", exclude)
-		}
-		for _, tag := range tags {
-			backupArgs = append(backupArgs, "--tag", tag)
-		}
-		backupArgs = append(backupArgs, req.Paths...)
-		err = restic(options.Repo, options.Env, backupArgs...)
+		reportProgress(req, proto.ProgressEvent{Phase: "backup"})
+		var res *proto.BackupResponse
+		err = resticJson(options.Repo, options.Env, func(line []byte) error {
+			var message resticBackupMessage
+			err := json.Unmarshal(line, &message)
+			if err != nil {
+				return err
+			}
+			switch message.MessageType {
+			case "status":
+				reportProgress(req, message.progressEvent())
+			case "error":
+				fmt.Fprintf(os.Stderr, "error during %s of %s: %s\n",
+					message.During, message.Item, message.Error.Message)
+			case "summary":
+				var extra map[string]any
+				err := json.Unmarshal(line, &extra)
+				if err != nil {
+					return err
+				}
+				res = message.backupResponse(extra)
+			}
+			return nil
+		}, backupArgs(req)...)
 		if err != nil {
-			return fmt.Errorf("failed to backup %v to repo %s: %w",
+			return nil, fmt.Errorf("failed to backup %v to repo %s: %w",
 				req.Paths, options.Repo, err)
 		}
 
 		if options.Forget.Enable {
-			forgetArgs := []string{"forget"}
-			forgetArgs = append(forgetArgs, "--tag", strings.Join(tags, ","))
-			forgetOptionArgs, err := optionsToArgs(options.Forget.Options)
+			reportProgress(req, proto.ProgressEvent{Phase: "forget"})
+			forgetArgs, err := forgetArgs(req, options.Forget)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			forgetArgs = append(forgetArgs, forgetOptionArgs...)
-			err = restic(options.Repo, options.Env, forgetArgs...)
+			err = resticStderr(options.Repo, options.Env, forgetArgs...)
 			if err != nil {
-				return fmt.Errorf("failed to forget %v to repo %s: %w",
+				return nil, fmt.Errorf("failed to forget %v to repo %s: %w",
 					req.Paths, options.Repo, err)
 			}
 		}
 
-		return nil
+		return res, nil
+	},
+	DryRun: func(req *proto.BackupRequest) (*proto.DryRunResponse, error) {
+		var options Options
+		err := mapstructure.Decode(req.RawOptions, &options)
+		if err != nil {
+			return nil, err
+		}
+
+		res := &proto.DryRunResponse{Actions: []string{}}
+		exists, err := checkRepoExists(options.Repo, options.Env)
+		if err != nil {
+			return nil, err
+		}
+		args := backupArgs(req)
+		if exists {
+			// Restic can tell us what it would back up without writing to the
+			// repo. This needs an existing repo.
+			dryRunArgs := append([]string{args[0], "--dry-run"}, args[1:]...)
+			err = resticJson(options.Repo, options.Env, func(line []byte) error {
+				var message resticBackupMessage
+				err := json.Unmarshal(line, &message)
+				if err != nil {
+					return err
+				}
+				if message.MessageType == "summary" {
+					return json.Unmarshal(line, &res.Extra)
+				}
+				return nil
+			}, dryRunArgs...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to dry run backup of %v to repo %s: %w",
+					req.Paths, options.Repo, err)
+			}
+		} else {
+			res.Actions = append(res.Actions,
+				resticCmd(options.Repo, options.Env, "init").String())
+		}
+		res.Actions = append(res.Actions, resticCmd(options.Repo, options.Env, args...).String())
+		if options.Forget.Enable {
+			forgetArgs, err := forgetArgs(req, options.Forget)
+			if err != nil {
+				return nil, err
+			}
+			res.Actions = append(res.Actions,
+				resticCmd(options.Repo, options.Env, forgetArgs...).String())
+		}
+		return res, nil
 	},
 	Exec: func(req *proto.ExecRequest) error {
 		var options Options
@@ -178,6 +231,48 @@ var Backend = &proto.BackendImpl{
 	},
 }
 
+// backupTags returns the tags that identify the backups of the given job &
+// destination. They're used to list and forget backups.
+func backupTags(req *proto.BackupRequest) []string {
+	tags := []string{
+		fmt.Sprintf("sb:dest:%s", req.DestinationName),
+		fmt.Sprintf("sb:job:%s", req.JobName),
+	}
+	if req.VariantName != "" {
+		tags = append(tags, fmt.Sprintf("sb:variant:%s", req.VariantName))
+	}
+	return tags
+}
+
+func backupArgs(req *proto.BackupRequest) []string {
+	args := []string{"backup", "--json"}
+	for _, exclude := range req.Exclude {
+		args = append(args, "--exclude", exclude)
+	}
+	for _, tag := range backupTags(req) {
+		args = append(args, "--tag", tag)
+	}
+	return append(args, req.Paths...)
+}
+
+func forgetArgs(req *proto.BackupRequest, forget Forget) ([]string, error) {
+	args := []string{"forget", "--tag", strings.Join(backupTags(req), ",")}
+	optionArgs, err := optionsToArgs(forget.Options)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, optionArgs...), nil
+}
+
+// reportProgress sends progress events to standard-backups. Progress is purely
+// informational so failing to report it should not fail the backup.
+func reportProgress(req *proto.BackupRequest, ev proto.ProgressEvent) {
+	err := req.ReportProgress(ev)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to report progress: %s\n", err)
+	}
+}
+
 func main() {
 	Backend.Execute()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -8,7 +9,9 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"time"
 
+	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/hashicorp/go-version"
 )
 
@@ -42,6 +45,17 @@ func restic(repo string, env map[string]string, args ...string) error {
 	return err
 }
 
+// resticStderr runs restic with its stdout sent to stderr. This is meant for
+// commands that run during a backup because stdout is reserved for the backup
+// response.
+func resticStderr(repo string, env map[string]string, args ...string) error {
+	cmd := resticCmd(repo, env, args...)
+	cmd.Stdout = os.Stderr
+	fmt.Fprintf(os.Stderr, "running restic: %s\n", cmd.String())
+	err := cmd.Run()
+	return err
+}
+
 func resticOutput(repo string, env map[string]string, args ...string) ([]byte, error) {
 	cmd := resticCmd(repo, env, args...)
 	output := bytes.NewBuffer(nil)
@@ -54,6 +68,100 @@ func resticOutput(repo string, env map[string]string, args ...string) ([]byte, e
 	return output.Bytes(), nil
 }
 
+// resticJson runs restic and calls onMessage for every JSON message that it
+// prints on stdout. Lines that are not JSON are forwarded to stderr. This is
+// meant to be used with commands that support `--json`.
+func resticJson(
+	repo string,
+	env map[string]string,
+	onMessage func(line []byte) error,
+	args ...string,
+) error {
+	cmd := resticCmd(repo, env, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "running restic: %s\n", cmd.String())
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	var messageErr error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, 1024*1024) // status lines list current files, they can get long
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !json.Valid(line) {
+			fmt.Fprintln(os.Stderr, string(line))
+			continue
+		}
+		if messageErr == nil {
+			messageErr = onMessage(line)
+		}
+	}
+	scanErr := scanner.Err()
+
+	err = cmd.Wait()
+	return errors.Join(err, scanErr, messageErr)
+}
+
+type resticBackupMessage struct {
+	MessageType      string  `json:"message_type"`
+	PercentDone      float64 `json:"percent_done"`
+	TotalFiles       int64   `json:"total_files"`
+	FilesDone        int64   `json:"files_done"`
+	TotalBytes       int64   `json:"total_bytes"`
+	BytesDone        int64   `json:"bytes_done"`
+	SecondsRemaining int64   `json:"seconds_remaining"`
+	Error            struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	During string `json:"during"`
+	Item   string `json:"item"`
+
+	// Only in summary messages
+	FilesNew            int64  `json:"files_new"`
+	FilesChanged        int64  `json:"files_changed"`
+	FilesUnmodified     int64  `json:"files_unmodified"`
+	DataAdded           int64  `json:"data_added"`
+	TotalFilesProcessed int64  `json:"total_files_processed"`
+	TotalBytesProcessed int64  `json:"total_bytes_processed"`
+	BackupEnd           string `json:"backup_end"` // restic >= 0.17
+	SnapshotId          string `json:"snapshot_id"`
+}
+
+func (m *resticBackupMessage) progressEvent() proto.ProgressEvent {
+	return proto.ProgressEvent{
+		Phase:            "backup",
+		PercentDone:      m.PercentDone,
+		FilesDone:        m.FilesDone,
+		TotalFiles:       m.TotalFiles,
+		BytesDone:        m.BytesDone,
+		TotalBytes:       m.TotalBytes,
+		SecondsRemaining: m.SecondsRemaining,
+	}
+}
+
+func (m *resticBackupMessage) backupResponse(extra map[string]any) *proto.BackupResponse {
+	t := m.BackupEnd
+	if t == "" {
+		t = time.Now().Format(time.RFC3339)
+	}
+	return &proto.BackupResponse{
+		Id:              m.SnapshotId,
+		Time:            t,
+		BytesAdded:      m.DataAdded,
+		BytesProcessed:  m.TotalBytesProcessed,
+		FilesNew:        m.FilesNew,
+		FilesChanged:    m.FilesChanged,
+		FilesUnmodified: m.FilesUnmodified,
+		FilesProcessed:  m.TotalFilesProcessed,
+		Extra:           extra,
+	}
+}
+
 func resticRawVersion() (string, error) {
 	cmd := exec.Command(resticBin(), "version", "--json")
 	stdout := bytes.NewBuffer(nil)
@@ -116,23 +224,38 @@ func checkRepoExists(repo string, env map[string]string) (bool, error) {
 	return true, nil
 }
 
+// optionsToArgs turns options into restic flags. Lists turn into repeated flags
+// (ex: tag: [a, b] becomes --tag a --tag b).
 func optionsToArgs(options map[string]any) ([]string, error) {
 	res := []string{}
 	for key, value := range options {
-		flag := fmt.Sprintf("--%s", key)
-		if b, ok := value.(bool); ok {
-			if b {
-				res = append(res, flag)
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			args, ok := optionToArgs(fmt.Sprintf("--%s", key), v)
+			if !ok {
+				return nil, fmt.Errorf("could not convert option %s: %v to restic flags", key, value)
 			}
-		} else if s, ok := value.(string); ok {
-			res = append(res, flag, s)
-		} else if i, ok := value.(int); ok {
-			res = append(res, flag, fmt.Sprint(i))
-		} else if f, ok := value.(float64); ok {
-			res = append(res, flag, fmt.Sprint(f))
-		} else {
-			return nil, fmt.Errorf("could not convert option %s: %v to restic flags", key, value)
+			res = append(res, args...)
 		}
 	}
 	return res, nil
 }
+
+func optionToArgs(flag string, value any) ([]string, bool) {
+	if b, ok := value.(bool); ok {
+		if b {
+			return []string{flag}, true
+		}
+		return []string{}, true
+	} else if s, ok := value.(string); ok {
+		return []string{flag, s}, true
+	} else if i, ok := value.(int); ok {
+		return []string{flag, fmt.Sprint(i)}, true
+	} else if f, ok := value.(float64); ok {
+		return []string{flag, fmt.Sprint(f)}, true
+	}
+	return nil, false
+}
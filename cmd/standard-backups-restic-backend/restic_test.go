@@ -51,6 +51,16 @@ func TestOptionsToArgs(t *testing.T) {
 			options: map[string]any{},
 			args:    []string{},
 		},
+		{
+			name:    "list",
+			options: map[string]any{"tag": []any{"foo", 42.0}},
+			args:    []string{"--tag", "foo", "--tag", "42"},
+		},
+		{
+			name:    "empty-list",
+			options: map[string]any{"tag": []any{}},
+			args:    []string{},
+		},
 	}
 	for _, test := range cases {
 		t.Run(test.name, func(t *testing.T) {
@@ -62,6 +72,18 @@ func TestOptionsToArgs(t *testing.T) {
 	}
 }
 
+func TestOptionsToArgsUnsupported(t *testing.T) {
+	for name, value := range map[string]any{
+		"object":      map[string]any{"foo": "bar"},
+		"nested-list": []any{[]any{"foo"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := optionsToArgs(map[string]any{"foo": value})
+			assert.ErrorContains(t, err, "could not convert option foo")
+		})
+	}
+}
+
 func TestCheckRepoExists(t *testing.T) {
 	tests := map[string]string{
 		"system": "restic",
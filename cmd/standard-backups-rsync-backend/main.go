@@ -18,35 +18,60 @@ type Options struct {
 }
 
 var Backend = &proto.BackendImpl{
-	Backup: func(req *proto.BackupRequest) error {
+	Backup: func(req *proto.BackupRequest) (*proto.BackupResponse, error) {
 		var options Options
 		err := mapstructure.Decode(req.RawOptions, &options)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		dest := path.Join(options.DestinationDir, time.Now().Format(TIME_FORMAT))
+		now := time.Now()
+		id := now.Format(TIME_FORMAT)
+		dest := path.Join(options.DestinationDir, id)
 		err = os.MkdirAll(dest, 0o755)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		args := []string{"-av"}
-		for _, exclude := range req.Exclude {
-			args = append(args, "--exclude", exclude)
-		}
-		args = append(args, req.Paths...)
-		args = append(args, dest)
-		cmd := exec.Command("rsync", args...)
+		cmd := rsyncCmd(req, dest)
 		cmd.Stdout = os.Stderr
 		cmd.Stderr = os.Stderr
-		fmt.Printf("running rsync: %s\n", cmd.String())
+		fmt.Fprintf(os.Stderr, "running rsync: %s\n", cmd.String())
 		err = cmd.Run()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return nil
+		return &proto.BackupResponse{
+			Id:    id,
+			Time:  now.Format(time.RFC3339),
+			Extra: map[string]any{"path": dest},
+		}, nil
 	},
+	DryRun: func(req *proto.BackupRequest) (*proto.DryRunResponse, error) {
+		var options Options
+		err := mapstructure.Decode(req.RawOptions, &options)
+		if err != nil {
+			return nil, err
+		}
+
+		dest := path.Join(options.DestinationDir, time.Now().Format(TIME_FORMAT))
+		return &proto.DryRunResponse{
+			Actions: []string{
+				fmt.Sprintf("mkdir -p %s", dest),
+				rsyncCmd(req, dest).String(),
+			},
+		}, nil
+	},
+}
+
+func rsyncCmd(req *proto.BackupRequest, dest string) *exec.Cmd {
+	args := []string{"-av"}
+	for _, exclude := range req.Exclude {
+		args = append(args, "--exclude", exclude)
+	}
+	args = append(args, req.Paths...)
+	args = append(args, dest)
+	return exec.Command("rsync", args...)
 }
 
 func main() {
@@ -56,15 +56,33 @@ func main() {
 
 	b := proto.BackendImpl{}
 	if impl.Backup.Enable {
-		b.Backup = func(req *proto.BackupRequest) error {
+		b.Backup = func(req *proto.BackupRequest) (*proto.BackupResponse, error) {
 			err := trace(traceDir, "backup", req)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			for _, ev := range impl.Backup.Progress {
+				err := req.ReportProgress(ev)
+				if err != nil {
+					return nil, err
+				}
 			}
 			if impl.Backup.Error != "" {
-				return errors.New(impl.Backup.Error)
+				return nil, errors.New(impl.Backup.Error)
 			}
-			return nil
+			return impl.Backup.Res, nil
+		}
+	}
+	if impl.DryRun.Enable {
+		b.DryRun = func(req *proto.BackupRequest) (*proto.DryRunResponse, error) {
+			err := trace(traceDir, "dry-run", req)
+			if err != nil {
+				return nil, err
+			}
+			if impl.DryRun.Error != "" {
+				return nil, errors.New(impl.DryRun.Error)
+			}
+			return impl.DryRun.Res, nil
 		}
 	}
 	if impl.Exec.Enable {
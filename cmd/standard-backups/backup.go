@@ -1,27 +1,164 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/dotboris/standard-backups/internal"
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/spf13/cobra"
 )
 
+var (
+	noProgress     bool
+	backupAll      bool
+	backupTags     []string
+	backupParallel int
+	backupDryRun   bool
+	backupIfDue    bool
+)
+
 var backupCmd = &cobra.Command{
-	Use:     "backup job",
-	Short:   "Perform a backup for the given job",
+	Use:   "backup [job...]",
+	Short: "Perform a backup for the given jobs",
+	Long: `Perform a backup for the given jobs. ` +
+		`Jobs can be selected by name, by tag with --tag, or all at once with --all. ` +
+		`When running more than one job, a summary of every job is printed at the end. ` +
+		`With --if-due, jobs that succeeded recently enough according to their schedule ` +
+		`or interval are skipped.`,
 	GroupID: "operations",
-	Args:    cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if backupAll && len(args) > 0 {
+			return errors.New("cannot pass job names along with --all")
+		}
+		if !backupAll && len(backupTags) == 0 && len(args) == 0 {
+			return errors.New("requires at least one job name, --tag or --all")
+		}
+		if backupParallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1, got %d", backupParallel)
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		jobName := args[0]
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
+		err = cfg.CheckSecretPermissions()
+		if err != nil {
+			return err
+		}
+		jobNames, err := cfg.MainConfig.SelectJobs(args, backupAll, backupTags)
+		if err != nil {
+			return err
+		}
+		if len(jobNames) == 0 {
+			return errors.New("there are no jobs to run")
+		}
+		if backupIfDue {
+			jobNames, err = filterDueJobs(cfg.MainConfig, jobNames)
+			if err != nil {
+				return err
+			}
+			if len(jobNames) == 0 {
+				slog.Info("no jobs are due")
+				return nil
+			}
+		}
+		if backupDryRun {
+			return dryRunBackups(cmd.Context(), redact.Stdout, cfg, jobNames)
+		}
+		for _, jobName := range jobNames {
+			err = requireJobCapabilities(cmd.Context(), cfg, jobName)
+			if err != nil {
+				return err
+			}
+		}
+
 		backupSvc := internal.NewBackupService()
-		err = backupSvc.Backup(*cfg, jobName)
-		return err
+		if shouldShowProgress() {
+			bar := newProgressBar(redact.Stderr)
+			backupSvc.OnProgress = func(job string, destination string, ev proto.ProgressEvent) {
+				if len(jobNames) > 1 {
+					destination = fmt.Sprintf("%s/%s", job, destination)
+				}
+				bar.Update(destination, ev)
+			}
+			defer bar.Clear()
+		}
+
+		if len(jobNames) == 1 {
+			_, err = backupSvc.Backup(cmd.Context(), *cfg, jobNames[0])
+			return err
+		}
+
+		results, err := backupSvc.BackupJobs(cmd.Context(), *cfg, jobNames, backupParallel)
+		renderErr := printBackupSummary(redact.Stdout, results)
+		if err != nil {
+			failed := 0
+			for _, res := range results {
+				if res.Err != nil {
+					failed++
+				}
+			}
+			return fmt.Errorf("%d of %d jobs failed", failed, len(results))
+		}
+		return renderErr
 	},
 }
 
+// filterDueJobs keeps the jobs that are due according to their schedule or
+// interval.
+func filterDueJobs(mc config.MainConfig, jobNames []string) ([]string, error) {
+	now := time.Now()
+	res := []string{}
+	for _, jobName := range jobNames {
+		nextDue, err := internal.NextDue(mc, jobName)
+		if err != nil {
+			return nil, err
+		}
+		if now.Before(nextDue) {
+			slog.Info("job is not due, skipping",
+				slog.String("job", jobName),
+				slog.Time("nextDue", nextDue))
+			continue
+		}
+		res = append(res, jobName)
+	}
+	return res, nil
+}
+
 func init() {
+	backupCmd.Flags().BoolVar(&noProgress,
+		"no-progress", false,
+		"Disable the progress bar",
+	)
+	backupCmd.Flags().BoolVar(&backupAll,
+		"all", false,
+		"Run all jobs",
+	)
+	backupCmd.Flags().StringSliceVarP(&backupTags,
+		"tag", "t",
+		nil,
+		"Run jobs with the given tag. Can be repeated",
+	)
+	backupCmd.Flags().IntVarP(&backupParallel,
+		"parallel", "p",
+		1,
+		"How many jobs to run at the same time",
+	)
+	backupCmd.Flags().BoolVar(&backupIfDue,
+		"if-due", false,
+		"Only run jobs that are due according to their schedule or interval",
+	)
+	backupCmd.Flags().BoolVar(&backupDryRun,
+		"dry-run", false,
+		"Print what would be done without backing anything up",
+	)
+	backupCmd.MarkFlagsMutuallyExclusive("all", "tag")
 	rootCmd.AddCommand(backupCmd)
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+)
+
+// requireCapability checks that the backend behind client supports the given
+// capability. subject is used in the error message to describe what the user
+// is trying to operate on (ex: `destination foo (restic)`). When the
+// capabilities of the backend can't be determined, we let the command through
+// and let the backend deal with it.
+func requireCapability(
+	ctx context.Context,
+	client *proto.BackendClient,
+	c proto.Capability,
+	subject string,
+) error {
+	caps, err := client.Capabilities(ctx)
+	if err != nil {
+		slog.Debug("could not determine backend capabilities, assuming it is capable",
+			slog.String("backend", client.Manifest.Name),
+			slog.String("capability", string(c)),
+			slog.Any("error", err))
+		return nil
+	}
+	if !caps.Has(c) {
+		return capabilityError(c, subject)
+	}
+	return nil
+}
+
+func capabilityError(c proto.Capability, subject string) error {
+	return fmt.Errorf("%s cannot %s", subject, c.Description())
+}
+
+func destinationSubject(destName string, backend string) string {
+	return fmt.Sprintf("destination %s (%s)", destName, backend)
+}
+
+// requireJobCapabilities checks that all the destinations of a job can perform
+// backups. This lets us fail before any of the hooks run.
+func requireJobCapabilities(ctx context.Context, cfg *config.Config, jobName string) error {
+	job, ok := cfg.MainConfig.Jobs[jobName]
+	if !ok {
+		return fmt.Errorf("could not find a job named %s", jobName)
+	}
+	for _, destName := range job.BackupTo {
+		dest, _, err := cfg.MainConfig.GetDestination(destName)
+		if err != nil {
+			return err
+		}
+		client, err := proto.NewBackendClient(*cfg, dest.Backend)
+		if err != nil {
+			return err
+		}
+		err = requireCapability(
+			ctx,
+			client,
+			proto.CapabilityBackup,
+			destinationSubject(destName, dest.Backend),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dotboris/standard-backups/internal"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run jobs on their schedule",
+	Long: `Run jobs at the times given by their schedule setting until stopped ` +
+		`with SIGINT or SIGTERM. Jobs without a schedule are left alone. ` +
+		`Send SIGHUP to reload the config. Running jobs finish with the config ` +
+		`they started with.`,
+	GroupID: "operations",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		defer signal.Stop(reload)
+
+		daemon := internal.NewDaemon(loadConfig)
+		return daemon.Run(cmd.Context(), reload)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/dotboris/standard-backups/internal"
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/goccy/go-yaml"
+)
+
+// dryRunBackups prints what backing up the given jobs would do. Backends are
+// asked what they would do when they support dry runs. Nothing gets backed up
+// and no hooks run.
+func dryRunBackups(ctx context.Context, w io.Writer, cfg *config.Config, jobNames []string) error {
+	for i, jobName := range jobNames {
+		plan, err := internal.PlanBackup(*cfg, jobName)
+		if err != nil {
+			return err
+		}
+		for j := range plan.Destinations {
+			err := dryRunDestination(ctx, cfg, &plan.Destinations[j])
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := yaml.Marshal(plan)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		_, err = w.Write(out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dryRunDestination(
+	ctx context.Context,
+	cfg *config.Config,
+	dest *internal.PlannedDestination,
+) error {
+	client, err := proto.NewBackendClient(*cfg, dest.Backend)
+	if err != nil {
+		return err
+	}
+	// Unlike other commands, we don't assume that the backend is capable when
+	// we can't tell. A backend that doesn't know about dry runs could end up
+	// performing a real backup.
+	caps, err := client.Capabilities(ctx)
+	if err != nil {
+		slog.Debug("could not determine backend capabilities",
+			slog.String("backend", dest.Backend),
+			slog.Any("error", err))
+		dest.Note = "could not determine if the backend supports dry runs"
+		return nil
+	}
+	if !caps.Has(proto.CapabilityDryRun) {
+		dest.Note = "backend does not support dry runs"
+		return nil
+	}
+
+	res, err := client.DryRun(ctx, dest.Request)
+	if err != nil {
+		return fmt.Errorf("dry run of %s failed: %w",
+			destinationSubject(dest.Destination, dest.Backend), err)
+	}
+	dest.BackendPlan = res
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/dotboris/standard-backups/internal/config"
 	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/spf13/cobra"
@@ -35,6 +37,10 @@ automatically configure the command to work with the specified destination.
 			if err != nil {
 				return err
 			}
+			err = cfg.CheckSecretPermissions()
+			if err != nil {
+				return err
+			}
 
 			var dest *config.DestinationConfigV1
 			var ref *config.DestinationRef
@@ -52,6 +58,15 @@ automatically configure the command to work with the specified destination.
 				return err
 			}
 
+			subject := fmt.Sprintf("backend %s", backend)
+			if ref != nil {
+				subject = destinationSubject(execDestination, backend)
+			}
+			err = requireCapability(cmd.Context(), client, proto.CapabilityExec, subject)
+			if err != nil {
+				return err
+			}
+
 			req := &proto.ExecRequest{
 				Args: args,
 			}
@@ -62,7 +77,7 @@ automatically configure the command to work with the specified destination.
 			if dest != nil {
 				req.RawOptions = dest.Options
 			}
-			err = client.Exec(req)
+			err = client.Exec(cmd.Context(), req)
 			return err
 		},
 		DisableFlagsInUseLine: true,
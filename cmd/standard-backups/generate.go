@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+var (
+	generateOutput          string
+	generateBin             string
+	generateRandomizedDelay time.Duration
+	generateNoHardening     bool
+)
+
+var generateCmd = &cobra.Command{
+	Use:     "generate",
+	Short:   "Generate files for other tools from the config",
+	GroupID: "config",
+}
+
+var generateSystemdCmd = &cobra.Command{
+	Use:   "systemd",
+	Short: "Generate systemd service and timer units for scheduled jobs",
+	Long: `Generate a systemd service and timer unit for every job with a schedule. ` +
+		`Units are printed to stdout unless --output is given. ` +
+		`The output only depends on the config and the flags so it can be regenerated ` +
+		`over and over again. With --output, units that were generated for jobs that ` +
+		`were removed or lost their schedule are deleted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		bin := generateBin
+		if bin == "" {
+			bin, err = os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the path of standard-backups, use --bin: %w", err)
+			}
+		}
+		absConfigPath, err := filepath.Abs(configPath)
+		if err != nil {
+			return err
+		}
+
+		units, err := systemd.Units(cfg.MainConfig, systemd.Options{
+			Bin:             bin,
+			ConfigPath:      absConfigPath,
+			RandomizedDelay: generateRandomizedDelay,
+			Hardening:       !generateNoHardening,
+		})
+		if err != nil {
+			return err
+		}
+
+		if generateOutput == "" {
+			for i, unit := range units {
+				if i > 0 {
+					fmt.Fprintln(redact.Stdout)
+				}
+				fmt.Fprintf(redact.Stdout, "# %s\n%s", unit.Name, unit.Content)
+			}
+			return nil
+		}
+
+		err = os.MkdirAll(generateOutput, 0o755)
+		if err != nil {
+			return err
+		}
+		for _, unit := range units {
+			p := path.Join(generateOutput, unit.Name)
+			// Leave unchanged files alone so that config management tools don't
+			// report changes
+			existing, err := os.ReadFile(p)
+			if err == nil && bytes.Equal(existing, []byte(unit.Content)) {
+				slog.Debug("unit is up to date", slog.String("path", p))
+				continue
+			}
+			err = os.WriteFile(p, []byte(unit.Content), 0o644)
+			if err != nil {
+				return err
+			}
+			slog.Info("wrote unit", slog.String("path", p))
+		}
+
+		stale, err := systemd.StaleUnits(generateOutput, units)
+		if err != nil {
+			return err
+		}
+		for _, p := range stale {
+			err = os.Remove(p)
+			if err != nil {
+				return err
+			}
+			slog.Info("removed unit of a job that is no longer scheduled", slog.String("path", p))
+		}
+		return nil
+	},
+}
+
+func init() {
+	generateSystemdCmd.Flags().StringVarP(&generateOutput,
+		"output", "o", "",
+		"Directory where to write the units (ex: /etc/systemd/system)",
+	)
+	generateSystemdCmd.Flags().StringVar(&generateBin,
+		"bin", "",
+		"Path of the standard-backups binary used in the units. Defaults to the running binary",
+	)
+	generateSystemdCmd.Flags().DurationVar(&generateRandomizedDelay,
+		"randomized-delay", 0,
+		"Delay timers by a random amount of time up to this long (RandomizedDelaySec)",
+	)
+	generateSystemdCmd.Flags().BoolVar(&generateNoHardening,
+		"no-hardening", false,
+		"Don't add sandboxing directives (ex: ProtectSystem) to the services",
+	)
+	generateCmd.AddCommand(generateSystemdCmd)
+	rootCmd.AddCommand(generateCmd)
+}
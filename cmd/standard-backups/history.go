@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyJson   bool
+	historyLimit  int
+	historyStatus string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [job]",
+	Short: "Show past backup runs",
+	Long: `Show past backup runs from the most recent to the oldest. ` +
+		`Runs can be filtered down to a single job by passing its name.`,
+	GroupID: "operations",
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch historyStatus {
+		case "", history.StatusSuccess, history.StatusFailure, history.StatusSkipped:
+		default:
+			return fmt.Errorf(
+				"unexpected value for --status. Got %s expected one of %s, %s, %s",
+				historyStatus,
+				history.StatusSuccess, history.StatusFailure, history.StatusSkipped,
+			)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		filter := history.Filter{Status: historyStatus, Limit: historyLimit}
+		if len(args) > 0 {
+			filter.Job = args[0]
+		}
+		store := history.NewStore(cfg.MainConfig.GetStateDir())
+		records, err := store.Read(filter)
+		if err != nil {
+			return err
+		}
+		slices.Reverse(records)
+
+		w := redact.Stdout
+		if historyJson {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		if len(records) == 0 {
+			slog.Info("no backup runs found", slog.String("path", store.Path()))
+			return nil
+		}
+
+		table := tablewriter.NewTable(w,
+			tablewriter.WithRendition(tw.Rendition{
+				Borders: tw.BorderNone,
+			}),
+		)
+		table.Header([]string{"job", "status", "start", "duration", "destinations", "error"})
+		for _, rec := range records {
+			succeeded := 0
+			for _, dest := range rec.Destinations {
+				if dest.Status == history.StatusSuccess {
+					succeeded++
+				}
+			}
+			errorLine, _, _ := strings.Cut(rec.Error, "\n")
+			err := table.Append([]string{
+				rec.Job,
+				rec.Status,
+				rec.StartTime.Local().Format(time.DateTime),
+				formatDuration(rec.EndTime.Sub(rec.StartTime)),
+				fmt.Sprintf("%d/%d", succeeded, len(rec.Destinations)),
+				errorLine,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintln(w)
+		return table.Render()
+	},
+}
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyJson,
+		"json", false,
+		"Print runs to stdout as JSON",
+	)
+	historyCmd.Flags().IntVarP(&historyLimit,
+		"limit", "n",
+		20,
+		"Maximum number of runs to show. 0 shows all runs",
+	)
+	historyCmd.Flags().StringVar(&historyStatus,
+		"status", "",
+		"Only show runs with the given status (success, failure, skipped)",
+	)
+	rootCmd.AddCommand(historyCmd)
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/gookit/color"
 	"github.com/spf13/cobra"
 )
@@ -44,6 +47,26 @@ var listBackendsCmd = &cobra.Command{
 			fmt.Fprintf(w, "  %s: %s\n",
 				color.Magenta.Text("bin"),
 				backend.Bin)
+
+			client := &proto.BackendClient{Manifest: backend}
+			capabilities := "(unknown)"
+			caps, err := client.Capabilities(cmd.Context())
+			if err != nil {
+				slog.Debug("failed to query backend capabilities",
+					slog.String("backend", backend.Name),
+					slog.Any("error", err))
+			} else if len(caps.Capabilities) == 0 {
+				capabilities = "(none)"
+			} else {
+				names := make([]string, len(caps.Capabilities))
+				for i, c := range caps.Capabilities {
+					names[i] = string(c)
+				}
+				capabilities = strings.Join(names, ", ")
+			}
+			fmt.Fprintf(w, "  %s: %s\n",
+				color.Magenta.Text("capabilities"),
+				capabilities)
 		}
 
 		return nil
@@ -33,6 +33,10 @@ var listBackupsCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		err = config.CheckSecretPermissions()
+		if err != nil {
+			return err
+		}
 
 		destName := args[0]
 		destination, ref, err := config.MainConfig.GetDestination(destName)
@@ -44,8 +48,17 @@ var listBackupsCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		err = requireCapability(
+			cmd.Context(),
+			client,
+			proto.CapabilityListBackups,
+			destinationSubject(destName, destination.Backend),
+		)
+		if err != nil {
+			return err
+		}
 
-		res, err := client.ListBackups(&proto.ListBackupsRequest{
+		res, err := client.ListBackups(cmd.Context(), &proto.ListBackupsRequest{
 			RawOptions:      destination.Options,
 			DestinationName: ref.Name,
 			VariantName:     ref.Variant,
@@ -150,32 +163,7 @@ func formatColumn(col string, backup proto.ListBackupsResponseItem) string {
 	case "variant":
 		return backup.Variant
 	case "size":
-		unit := "B"
-		size := float64(backup.Size)
-		if size >= 1024 {
-			size = size / 1024
-			unit = "KB"
-		}
-		if size >= 1024 {
-			size = size / 1024
-			unit = "MB"
-		}
-		if size >= 1024 {
-			size = size / 1024
-			unit = "GB"
-		}
-		if size >= 1024 {
-			size = size / 1024
-			unit = "TB"
-		}
-		if size >= 1024 {
-			size = size / 1024
-			unit = "PB"
-		}
-		formatted := fmt.Sprintf("%.2f", size)
-		formatted = strings.TrimRight(formatted, "0")
-		formatted = strings.TrimRight(formatted, ".")
-		return fmt.Sprintf("%s %s", formatted, unit)
+		return formatSize(int64(backup.Size))
 	default:
 		if col, ok := strings.CutPrefix(col, "extra."); ok {
 			parts := strings.Split(col, ".")
@@ -196,3 +184,32 @@ func formatColumn(col string, backup proto.ListBackupsResponseItem) string {
 		return ""
 	}
 }
+
+func formatSize(bytes int64) string {
+	unit := "B"
+	size := float64(bytes)
+	if size >= 1024 {
+		size = size / 1024
+		unit = "KB"
+	}
+	if size >= 1024 {
+		size = size / 1024
+		unit = "MB"
+	}
+	if size >= 1024 {
+		size = size / 1024
+		unit = "GB"
+	}
+	if size >= 1024 {
+		size = size / 1024
+		unit = "TB"
+	}
+	if size >= 1024 {
+		size = size / 1024
+		unit = "PB"
+	}
+	formatted := fmt.Sprintf("%.2f", size)
+	formatted = strings.TrimRight(formatted, "0")
+	formatted = strings.TrimRight(formatted, ".")
+	return fmt.Sprintf("%s %s", formatted, unit)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dotboris/standard-backups/pkg/proto"
+)
+
+const progressBarWidth = 20
+
+// progressBar renders progress events reported by backends on a single line
+// that gets redrawn as new events come in. When backing up to multiple
+// destinations in parallel, the latest event of each one is shown side by side.
+type progressBar struct {
+	w            io.Writer
+	lock         sync.Mutex
+	visible      bool
+	destinations []string
+	latest       map[string]proto.ProgressEvent
+}
+
+func newProgressBar(w io.Writer) *progressBar {
+	return &progressBar{w: w, latest: map[string]proto.ProgressEvent{}}
+}
+
+// shouldShowProgress returns true if progress bars make sense on stderr. They
+// don't when logging JSON or when stderr is not a terminal (ex: cron, systemd).
+func shouldShowProgress() bool {
+	if logJson || noProgress {
+		return false
+	}
+	stat, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *progressBar) Update(destination string, ev proto.ProgressEvent) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.latest[destination]; !ok {
+		p.destinations = append(p.destinations, destination)
+	}
+	p.latest[destination] = ev
+	parts := make([]string, len(p.destinations))
+	for i, d := range p.destinations {
+		parts[i] = formatProgress(d, p.latest[d])
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s", strings.Join(parts, " | "))
+	p.visible = true
+}
+
+// Clear erases the progress bar so that it doesn't get mixed in with the
+// output that follows.
+func (p *progressBar) Clear() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.visible {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.visible = false
+	}
+}
+
+func formatProgress(destination string, ev proto.ProgressEvent) string {
+	parts := []string{destination}
+	if ev.Phase != "" {
+		parts = append(parts, fmt.Sprintf("(%s)", ev.Phase))
+	}
+	if ev.PercentDone > 0 {
+		filled := min(int(ev.PercentDone*progressBarWidth), progressBarWidth)
+		parts = append(parts, fmt.Sprintf("[%s%s] %3.0f%%",
+			strings.Repeat("#", filled),
+			strings.Repeat(" ", progressBarWidth-filled),
+			ev.PercentDone*100))
+	}
+	if ev.TotalFiles > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d files", ev.FilesDone, ev.TotalFiles))
+	} else if ev.FilesDone > 0 {
+		parts = append(parts, fmt.Sprintf("%d files", ev.FilesDone))
+	}
+	if ev.TotalBytes > 0 {
+		parts = append(parts, fmt.Sprintf("%s/%s",
+			formatSize(ev.BytesDone), formatSize(ev.TotalBytes)))
+	} else if ev.BytesDone > 0 {
+		parts = append(parts, formatSize(ev.BytesDone))
+	}
+	if ev.SecondsRemaining > 0 {
+		parts = append(parts, fmt.Sprintf("ETA %s",
+			time.Duration(ev.SecondsRemaining)*time.Second))
+	}
+	return strings.Join(parts, " ")
+}
@@ -19,6 +19,10 @@ var restoreCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		err = config.CheckSecretPermissions()
+		if err != nil {
+			return err
+		}
 
 		destination, ref, err := config.MainConfig.GetDestination(destName)
 		if err != nil {
@@ -29,8 +33,17 @@ var restoreCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		err = requireCapability(
+			cmd.Context(),
+			client,
+			proto.CapabilityRestore,
+			destinationSubject(destName, destination.Backend),
+		)
+		if err != nil {
+			return err
+		}
 
-		err = client.Restore(&proto.RestoreRequest{
+		err = client.Restore(cmd.Context(), &proto.RestoreRequest{
 			RawOptions:      destination.Options,
 			DestinationName: ref.Name,
 			VariantName:     ref.Variant,
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"syscall"
 
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/internal/redact"
 	"github.com/phsym/console-slog"
 	"github.com/spf13/cobra"
@@ -62,9 +66,36 @@ var rootCmd = &cobra.Command{
 	SilenceUsage: true,
 }
 
+// exitCodeError makes the process exit with a specific code instead of 1.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 func Execute() {
-	err := rootCmd.Execute()
+	// SIGINT & SIGTERM cancel the command's context. This forwards the signal
+	// to running backends and hooks and lets the after & on-failure hooks run.
+	// A second signal kills them and exits right away.
+	ctx, stop := process.WithSignals(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
+		var signalErr *process.SignalError
+		if errors.As(context.Cause(ctx), &signalErr) {
+			os.Exit(signalErr.ExitCode())
+		}
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/internal/status"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/spf13/cobra"
+)
+
+var statusJson bool
+
+type jobStatusReport struct {
+	Job         string         `json:"job"`
+	Verdict     status.Verdict `json:"verdict"`
+	LastSuccess *time.Time     `json:"last_success"`
+	LastFailure *time.Time     `json:"last_failure"`
+	LastError   string         `json:"last_error,omitempty"`
+	// Time since the last successful backup in seconds
+	AgeSeconds *int64 `json:"age_seconds"`
+	// 0 when the job has no max age
+	MaxAgeSeconds int64 `json:"max_age_seconds"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show how fresh the backups of every job are",
+	Long: `Show the last success and the last failure of every job along with a verdict:
+
+  OK       the last run succeeded and it's more recent than the job's max-age
+  STALE    the last successful run is older than the job's max-age
+  FAILING  the last run failed
+  PENDING  the job never ran and it has no max-age, it's not a problem yet
+
+The exit code follows the conventions of Nagios and Icinga checks and comes
+from the worst job: 0 when all jobs are OK or PENDING, 1 (warning) when a job
+is STALE, 2 (critical) when a job is FAILING and 3 (unknown) when the status
+can't be determined. Give jobs a max-age to be warned about the ones that
+never run.`,
+	GroupID: "operations",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return &exitCodeError{code: status.VerdictUnknown.ExitCode(), err: err}
+		}
+
+		now := time.Now()
+		store := status.NewStore(cfg.MainConfig.GetStateDir())
+		worst := status.VerdictOk
+		notOk := 0
+		reports := []jobStatusReport{}
+		for _, jobName := range slices.Sorted(maps.Keys(cfg.MainConfig.Jobs)) {
+			job := cfg.MainConfig.Jobs[jobName]
+			js, err := store.Load(jobName)
+			if err != nil {
+				return &exitCodeError{code: status.VerdictUnknown.ExitCode(), err: err}
+			}
+			report := jobStatusReport{
+				Job:           jobName,
+				Verdict:       js.Verdict(now, job.MaxAge),
+				MaxAgeSeconds: int64(job.MaxAge.Seconds()),
+			}
+			if js != nil {
+				report.LastSuccess = js.LastSuccess
+				report.LastFailure = js.LastFailure
+				report.LastError = js.LastError
+			}
+			if report.LastSuccess != nil {
+				age := int64(now.Sub(*report.LastSuccess).Seconds())
+				report.AgeSeconds = &age
+			}
+			if report.Verdict.Worse(worst) {
+				worst = report.Verdict
+			}
+			if report.Verdict.Worse(status.VerdictOk) {
+				notOk++
+			}
+			reports = append(reports, report)
+		}
+
+		w := redact.Stdout
+		if statusJson {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(reports)
+		} else {
+			err = printStatusTable(w, reports)
+		}
+		if err != nil {
+			return err
+		}
+
+		if worst.Worse(status.VerdictOk) {
+			return &exitCodeError{
+				code: worst.ExitCode(),
+				err:  fmt.Errorf("%d of %d jobs are not OK", notOk, len(reports)),
+			}
+		}
+		return nil
+	},
+}
+
+func printStatusTable(w io.Writer, reports []jobStatusReport) error {
+	table := tablewriter.NewTable(w,
+		tablewriter.WithRendition(tw.Rendition{
+			Borders: tw.BorderNone,
+		}),
+	)
+	table.Header([]string{"job", "verdict", "last success", "last failure", "age", "max age"})
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return t.Local().Format(time.DateTime)
+	}
+	for _, report := range reports {
+		age := "-"
+		if report.AgeSeconds != nil {
+			age = formatDuration(time.Duration(*report.AgeSeconds) * time.Second)
+		}
+		maxAge := "-"
+		if report.MaxAgeSeconds > 0 {
+			maxAge = formatDuration(time.Duration(report.MaxAgeSeconds) * time.Second)
+		}
+		err := table.Append([]string{
+			report.Job,
+			string(report.Verdict),
+			formatTime(report.LastSuccess),
+			formatTime(report.LastFailure),
+			age,
+			maxAge,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w)
+	return table.Render()
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJson,
+		"json", false,
+		"Print the status of every job to stdout as JSON",
+	)
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+// printBackupSummary prints a table with the outcome of every job.
+func printBackupSummary(w io.Writer, results []internal.JobResult) error {
+	table := tablewriter.NewTable(w,
+		tablewriter.WithRendition(tw.Rendition{
+			Borders: tw.BorderNone,
+		}),
+	)
+	table.Header([]string{"job", "status", "duration", "destinations"})
+	for _, res := range results {
+		status := "ok"
+		if res.Skipped {
+			status = "skipped"
+		} else if res.Err != nil {
+			status = "failed"
+		}
+		succeeded := 0
+		for _, dest := range res.Destinations {
+			if dest.Err == nil {
+				succeeded++
+			}
+		}
+		err := table.Append([]string{
+			res.Job,
+			status,
+			formatDuration(res.EndTime.Sub(res.StartTime)),
+			fmt.Sprintf("%d/%d", succeeded, len(res.Destinations)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w)
+	return table.Render()
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
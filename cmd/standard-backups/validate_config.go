@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +22,7 @@ var validateConfigCmd = &cobra.Command{
 		}
 
 		errs := c.Validate()
+		errs = append(errs, validateCapabilities(cmd.Context(), c, errs)...)
 		if len(errs) > 0 {
 			byFile := map[string][]config.ValidationError{}
 			for _, err := range errs {
@@ -39,6 +43,66 @@ var validateConfigCmd = &cobra.Command{
 	},
 }
 
+// validateCapabilities checks that the destinations used by jobs are bound to
+// backends that can perform backups. Backends that already have validation
+// errors are skipped since we can't expect to be able to talk to them. So are
+// backends that can't tell us what they're capable of.
+func validateCapabilities(
+	ctx context.Context,
+	c *config.Config,
+	errs []config.ValidationError,
+) []config.ValidationError {
+	res := []config.ValidationError{}
+
+	broken := map[string]bool{}
+	for _, err := range errs {
+		broken[err.File] = true
+	}
+
+	caps := map[string]*proto.CapabilitiesResponse{}
+	for jobName, job := range c.MainConfig.Jobs {
+		for destIndex, destName := range job.BackupTo {
+			dest, _, err := c.MainConfig.GetDestination(destName)
+			if err != nil {
+				// Already reported by config.Validate
+				continue
+			}
+			manifest, err := c.GetBackendManifest(dest.Backend)
+			if err != nil || broken[manifest.Path] {
+				continue
+			}
+
+			backendCaps, ok := caps[dest.Backend]
+			if !ok {
+				client := &proto.BackendClient{Manifest: *manifest}
+				backendCaps, err = client.Capabilities(ctx)
+				if err != nil {
+					slog.Debug("could not determine backend capabilities, skipping checks",
+						slog.String("backend", dest.Backend),
+						slog.Any("error", err))
+				}
+				caps[dest.Backend] = backendCaps
+			}
+			if backendCaps == nil {
+				continue
+			}
+
+			if !backendCaps.Has(proto.CapabilityBackup) {
+				res = append(res, config.ValidationError{
+					File:      c.MainConfig.Path(),
+					FieldPath: fmt.Sprintf("/jobs/%s/backup-to/%d", jobName, destIndex),
+					Err: capabilityError(
+						proto.CapabilityBackup,
+						destinationSubject(destName, dest.Backend),
+					),
+				})
+			}
+		}
+	}
+
+	return res
+}
+
 func init() {
 	rootCmd.AddCommand(validateConfigCmd)
 }
@@ -2,11 +2,20 @@ package e2e
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"path"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/dotboris/standard-backups/internal/history"
 	"github.com/dotboris/standard-backups/internal/testbackend"
 	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/gkampitakis/go-snaps/snaps"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -69,8 +78,10 @@ func TestBackup(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			tc := testutils.NewTestConfig(t)
 			tb := testbackend.New(t, testbackend.Impl{
-				Backup: testbackend.BaseImpl{
-					Enable: true,
+				Backup: testbackend.BackupImpl{
+					BaseImpl: testbackend.BaseImpl{
+						Enable: true,
+					},
 				},
 			})
 			tb.AddSelf(tc)
@@ -92,9 +103,11 @@ func TestBackup(t *testing.T) {
 func TestBackupError(t *testing.T) {
 	tc := testutils.NewTestConfig(t)
 	tb := testbackend.New(t, testbackend.Impl{
-		Backup: testbackend.BaseImpl{
-			Enable: true,
-			Error:  "oops",
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{
+				Enable: true,
+				Error:  "oops",
+			},
 		},
 	})
 	tb.AddSelf(tc)
@@ -115,6 +128,187 @@ func TestBackupError(t *testing.T) {
 	assert.Contains(t, stderr.String(), "Error: oops\n")
 }
 
+func TestBackupMultipleJobs(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.AddRecipe("broken", testutils.DedentYaml(`
+		version: 1
+		name: broken
+		paths: [/nope]
+		before:
+			shell: sh
+			command: exit 1
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			good:
+				recipe: bogus
+				backup-to: [my-dest]
+				tags: [nightly]
+			bad:
+				recipe: broken
+				backup-to: [my-dest]
+				tags: [nightly]
+			untagged:
+				recipe: bogus
+				backup-to: [my-dest]
+	`))
+
+	t.Run("by tag", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "backup", "--tag", "nightly", "--parallel", "2")
+		tc.Apply(cmd)
+		tb.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		stderr := bytes.NewBufferString("")
+		cmd.Stderr = stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if assert.ErrorAs(t, err, &exitErr, stderr.String()) {
+			assert.Equal(t, 1, exitErr.ExitCode())
+		}
+		assert.Contains(t, stderr.String(), "Error: 1 of 2 jobs failed\n")
+		assert.Regexp(t, `(?m)^\s*bad\s+│\s+failed\s+│`, stdout.String())
+		assert.Regexp(t, `(?m)^\s*good\s+│\s+ok\s+│.*│\s+1/1\s*$`, stdout.String())
+		assert.NotContains(t, stdout.String(), "untagged")
+	})
+
+	t.Run("by name", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "backup", "good", "untagged")
+		tc.Apply(cmd)
+		tb.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		err := cmd.Run()
+		require.NoError(t, err)
+		assert.Regexp(t, `(?m)^\s*good\s+│\s+ok\s+│`, stdout.String())
+		assert.Regexp(t, `(?m)^\s*untagged\s+│\s+ok\s+│`, stdout.String())
+	})
+
+	t.Run("no jobs", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "backup")
+		tc.Apply(cmd)
+		stderr := bytes.NewBufferString("")
+		cmd.Stderr = stderr
+		err := cmd.Run()
+		assert.Error(t, err)
+		assert.Contains(t, stderr.String(), "requires at least one job name, --tag or --all")
+	})
+}
+
+func TestBackupDryRun(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+		},
+		DryRun: testbackend.DryRunImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+			Res: &proto.DryRunResponse{
+				Actions: []string{"copy everything"},
+			},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddRecipe("bogus", testutils.DedentYaml(`
+		version: 1
+		name: bogus
+		paths: [/path/to/backup]
+		exclude: [exclude/me]
+		before:
+			shell: sh
+			command: echo before
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+				options:
+					password: '{{ .Secrets.password }}'
+					number: 42
+				variants:
+					my-variant:
+						number: 69
+		jobs:
+			my-job:
+				recipe: bogus
+				backup-to: [my-dest/my-variant]
+		secrets:
+			password:
+				literal: hunter2
+	`))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job", "--dry-run")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	stdout := bytes.NewBufferString("")
+	cmd.Stdout = stdout
+	err := cmd.Run()
+	require.NoError(t, err)
+
+	assert.Equal(t, testutils.Dedent(`
+		job: my-job
+		recipe: bogus
+		paths:
+		- /path/to/backup
+		exclude:
+		- exclude/me
+		hooks:
+		- name: before
+		  when: before backing up
+		  shell: sh
+		  command: echo before
+		destinations:
+		- destination: my-dest/my-variant
+		  backend: test
+		  options:
+		    number: 69
+		    password: ***
+		  backend-plan:
+		    actions:
+		    - copy everything
+	`)+"\n", stdout.String())
+
+	var req map[string]any
+	err = json.Unmarshal(tb.RequireTrace("dry-run"), &req)
+	require.NoError(t, err)
+	assert.Equal(t, "my-variant", req["VariantName"])
+	assert.NoFileExists(t, path.Join(tb.TraceDir(), "backup.json"))
+}
+
+func TestBackupDryRunNotSupported(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(testBackupConfigFull))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job", "--dry-run")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	stdout := bytes.NewBufferString("")
+	cmd.Stdout = stdout
+	err := cmd.Run()
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "note: backend does not support dry runs\n")
+	assert.NoFileExists(t, path.Join(tb.TraceDir(), "backup.json"))
+}
+
 func TestBackupNotImplemented(t *testing.T) {
 	tc := testutils.NewTestConfig(t)
 	tb := testbackend.New(t, testbackend.Impl{})
@@ -133,5 +327,333 @@ func TestBackupNotImplemented(t *testing.T) {
 	require.Error(t, err)
 	assert.ErrorAs(t, err, &exitError)
 	assert.Equal(t, 1, exitError.ExitCode())
-	assert.Contains(t, stderr.String(), "Error: unhandled command backup\n")
+	assert.Contains(
+		t,
+		stderr.String(),
+		"Error: destination my-dest/my-variant (test) cannot perform backups\n",
+	)
+}
+
+func TestBackupProgress(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{
+				Enable: true,
+			},
+			Progress: []proto.ProgressEvent{
+				{Phase: "backup", PercentDone: 0.5, FilesDone: 1, TotalFiles: 2},
+				{Phase: "backup", PercentDone: 1, FilesDone: 2, TotalFiles: 2},
+			},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(testBackupConfigFull))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job", "--log-json", "--log-level", "debug")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	require.NoError(t, err, stderr.String())
+
+	type progressLog struct {
+		Msg         string
+		Level       string
+		Destination string
+		Phase       string
+		PercentDone float64
+		FilesDone   int64
+	}
+	progress := []progressLog{}
+	for line := range strings.Lines(stderr.String()) {
+		var log progressLog
+		if json.Unmarshal([]byte(line), &log) == nil && log.Msg == "backup progress" {
+			progress = append(progress, log)
+		}
+	}
+	assert.Equal(t, []progressLog{
+		{"backup progress", "INFO", "my-dest/my-variant", "backup", 0, 0},
+		{"backup progress", "DEBUG", "my-dest/my-variant", "backup", 0.5, 1},
+		{"backup progress", "DEBUG", "my-dest/my-variant", "backup", 1, 2},
+	}, progress)
+}
+
+func TestBackupResponse(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{
+				Enable: true,
+			},
+			Res: &proto.BackupResponse{
+				Id:             "abc123",
+				BytesAdded:     42,
+				FilesProcessed: 3,
+			},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(testBackupConfigFull))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job", "--log-json")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	stdout := bytes.NewBufferString("")
+	cmd.Stdout = stdout
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	require.NoError(t, err, stderr.String())
+	assert.Empty(t, stdout.String(), "backup response leaked to stdout")
+
+	type responseLog struct {
+		Msg            string
+		Destination    string
+		Id             string
+		BytesAdded     int64
+		FilesProcessed int64
+	}
+	responses := []responseLog{}
+	for line := range strings.Lines(stderr.String()) {
+		var log responseLog
+		if json.Unmarshal([]byte(line), &log) == nil && log.Msg == "backup created" {
+			responses = append(responses, log)
+		}
+	}
+	assert.Equal(t, []responseLog{
+		{"backup created", "my-dest/my-variant", "abc123", 42, 3},
+	}, responses)
+}
+
+func TestBackupTimeout(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	logFile := path.Join(t.TempDir(), "log.txt")
+	backend := testutils.NewBlockingBackend(t, "blocking", logFile)
+	tc.AddBackend("blocking", backend.Path)
+	tc.AddRecipe("maintenance", testutils.DedentYaml(fmt.Sprintf(`
+		version: 1
+		name: maintenance
+		paths: [/nope]
+		before:
+			shell: bash
+			command: echo maintenance on >> %[1]s
+		after:
+			shell: bash
+			command: echo maintenance off >> %[1]s
+	`, logFile)))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			hangs:
+				backend: blocking
+				timeout: 200ms
+		jobs:
+			my-job:
+				recipe: maintenance
+				backup-to: [hangs]
+	`))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job")
+	tc.Apply(cmd)
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr, stderr.String()) {
+		assert.Equal(t, 1, exitErr.ExitCode())
+	}
+	assert.Contains(t, stderr.String(), "timed out after 200ms")
+
+	log, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, testutils.Dedent(`
+		maintenance on
+		blocking: started
+		blocking: waiting
+		maintenance off
+	`)+"\n", string(log))
+}
+
+func TestBackupLocked(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	logFile := path.Join(t.TempDir(), "log.txt")
+	backend := testutils.NewBlockingBackend(t, "blocking", logFile)
+	tc.AddBackend("blocking", backend.Path)
+	tc.AddRecipe("my-recipe", testutils.DedentYaml(`
+		version: 1
+		name: my-recipe
+		paths: [/nope]
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			hangs:
+				backend: blocking
+		jobs:
+			my-job:
+				recipe: my-recipe
+				backup-to: [hangs]
+	`))
+
+	first := testutils.StandardBackups(t, "backup", "my-job")
+	tc.Apply(first)
+	require.NoError(t, first.Start())
+	assert.Eventually(t, func() bool {
+		log, _ := os.ReadFile(logFile)
+		return strings.Contains(string(log), "blocking: waiting")
+	}, 10*time.Second, 50*time.Millisecond)
+
+	second := testutils.StandardBackups(t, "backup", "my-job")
+	tc.Apply(second)
+	stderr := bytes.NewBufferString("")
+	second.Stderr = stderr
+	err := second.Run()
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr, stderr.String()) {
+		assert.Equal(t, 1, exitErr.ExitCode())
+	}
+	assert.Contains(t, stderr.String(), "job my-job is already running")
+	assert.Contains(t, stderr.String(), fmt.Sprintf("locked by process %d", first.Process.Pid))
+
+	backend.Unblock()
+	assert.NoError(t, first.Wait())
+}
+
+func TestBackupSignal(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	logFile := path.Join(t.TempDir(), "log.txt")
+	backend := testutils.NewBlockingBackend(t, "blocking", logFile)
+	tc.AddBackend("blocking", backend.Path)
+	tc.AddRecipe("maintenance", testutils.DedentYaml(fmt.Sprintf(`
+		version: 1
+		name: maintenance
+		paths: [/nope]
+		before:
+			shell: bash
+			command: echo maintenance on >> %[1]s
+		after:
+			shell: bash
+			command: echo maintenance off >> %[1]s
+	`, logFile)))
+	tc.WriteConfig(testutils.DedentYaml(fmt.Sprintf(`
+		version: 1
+		destinations:
+			hangs:
+				backend: blocking
+		jobs:
+			my-job:
+				recipe: maintenance
+				backup-to: [hangs]
+				on-failure:
+					shell: bash
+					command: echo on-failure >> %s
+	`, logFile)))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job")
+	tc.Apply(cmd)
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	require.NoError(t, cmd.Start())
+	assert.Eventually(t, func() bool {
+		log, _ := os.ReadFile(logFile)
+		return strings.Contains(string(log), "blocking: waiting")
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr, stderr.String()) {
+		assert.Equal(t, 128+int(syscall.SIGTERM), exitErr.ExitCode())
+	}
+	assert.Contains(t, stderr.String(), "received signal terminated")
+
+	log, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, testutils.Dedent(`
+		maintenance on
+		blocking: started
+		blocking: waiting
+		maintenance off
+		on-failure
+	`)+"\n", string(log))
+}
+
+func TestBackupIfDue(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.AddRecipe("broken", testutils.DedentYaml(`
+		version: 1
+		name: broken
+		paths: [/nope]
+		before:
+			shell: sh
+			command: exit 1
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			hourly:
+				recipe: bogus
+				backup-to: [my-dest]
+				interval: 1h
+			nightly:
+				recipe: bogus
+				backup-to: [my-dest]
+				schedule: "0 3 * * *"
+			failing:
+				recipe: broken
+				backup-to: [my-dest]
+				interval: 1h
+	`))
+
+	for range 2 {
+		cmd := testutils.StandardBackups(t, "backup", "--all", "--if-due")
+		tc.Apply(cmd)
+		tb.Apply(cmd)
+		_ = cmd.Run()
+	}
+
+	records, err := history.NewStore(path.Join(tc.StateDir, "standard-backups")).
+		Read(history.Filter{})
+	require.NoError(t, err)
+	runs := map[string]int{}
+	for _, rec := range records {
+		runs[rec.Job]++
+	}
+	// Jobs that failed are retried until they succeed
+	assert.Equal(t, map[string]int{"hourly": 1, "nightly": 1, "failing": 2}, runs)
+
+	// Nothing left to do
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			hourly:
+				recipe: bogus
+				backup-to: [my-dest]
+				interval: 1h
+	`))
+	cmd := testutils.StandardBackups(t, "backup", "hourly", "--if-due")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	require.NoError(t, cmd.Run())
+	assert.Contains(t, stderr.String(), "job is not due, skipping")
+	assert.Contains(t, stderr.String(), "no jobs are due")
 }
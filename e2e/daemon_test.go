@@ -0,0 +1,64 @@
+package e2e
+
+import (
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonReloadAndStop(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		jobs:
+			my-job:
+				recipe: bogus
+				backup-to: []
+				schedule: "0 3 * * *"
+	`))
+
+	logPath := path.Join(t.TempDir(), "stderr.log")
+	logFile, err := os.Create(logPath)
+	require.NoError(t, err)
+	defer logFile.Close()
+	cmd := testutils.StandardBackups(t, "daemon", "--log-json")
+	tc.Apply(cmd)
+	cmd.Stderr = logFile
+	require.NoError(t, cmd.Start())
+
+	waitForLog := func(s string) {
+		t.Helper()
+		assert.Eventually(t, func() bool {
+			log, _ := os.ReadFile(logPath)
+			return strings.Contains(string(log), s)
+		}, 10*time.Second, 50*time.Millisecond, "waiting for %q in log", s)
+	}
+	waitForLog(`"msg":"daemon started"`)
+	log, _ := os.ReadFile(logPath)
+	assert.Contains(t, string(log), `"schedule":"0 3 * * *"`)
+
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		jobs:
+			my-job:
+				recipe: bogus
+				backup-to: []
+				schedule: "@hourly"
+	`))
+	require.NoError(t, cmd.Process.Signal(syscall.SIGHUP))
+	waitForLog(`"msg":"reloaded config"`)
+	log, _ = os.ReadFile(logPath)
+	assert.Contains(t, string(log), `"schedule":"@hourly"`)
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	assert.NoError(t, cmd.Wait())
+	waitForLog(`"msg":"stopping daemon"`)
+}
@@ -130,11 +130,20 @@ func TestExecError(t *testing.T) {
 }
 
 func TestExecNotImplemented(t *testing.T) {
-	testCases := map[string][]string{
-		"backend":     testExecArgsBackend,
-		"destination": testExecArgsDestination,
+	testCases := map[string]struct {
+		args    []string
+		message string
+	}{
+		"backend": {
+			args:    testExecArgsBackend,
+			message: "Error: backend test cannot execute commands\n",
+		},
+		"destination": {
+			args:    testExecArgsDestination,
+			message: "Error: destination my-dest (test) cannot execute commands\n",
+		},
 	}
-	for name, args := range testCases {
+	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
 			tc := testutils.NewTestConfig(t)
 			tb := testbackend.New(t, testbackend.Impl{})
@@ -142,7 +151,7 @@ func TestExecNotImplemented(t *testing.T) {
 			tc.WriteConfig(testutils.DedentYaml(testExecConfigFull))
 
 			cmd := testutils.StandardBackups(t, "exec")
-			cmd.Args = append(cmd.Args, args...)
+			cmd.Args = append(cmd.Args, testCase.args...)
 			tc.Apply(cmd)
 			tb.Apply(cmd)
 			stderr := bytes.NewBufferString("")
@@ -153,7 +162,7 @@ func TestExecNotImplemented(t *testing.T) {
 			require.Error(t, err)
 			assert.ErrorAs(t, err, &exitError)
 			assert.Equal(t, 1, exitError.ExitCode())
-			assert.Contains(t, stderr.String(), "Error: unhandled command exec\n")
+			assert.Contains(t, stderr.String(), testCase.message)
 		})
 	}
 }
@@ -0,0 +1,108 @@
+package e2e
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSystemd(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		jobs:
+			nightly:
+				recipe: bogus
+				backup-to: []
+				schedule: "0 3 * * *"
+			manual:
+				recipe: bogus
+				backup-to: []
+	`))
+	outDir := path.Join(t.TempDir(), "units")
+
+	generate := func() {
+		t.Helper()
+		cmd := testutils.StandardBackups(t, "generate", "systemd",
+			"--output", outDir,
+			"--bin", "/usr/bin/standard-backups",
+			"--randomized-delay", "15m",
+		)
+		tc.Apply(cmd)
+		require.NoError(t, cmd.Run())
+	}
+	generate()
+
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{
+		"standard-backups-nightly.service",
+		"standard-backups-nightly.timer",
+	}, names)
+
+	service, err := os.ReadFile(path.Join(outDir, "standard-backups-nightly.service"))
+	require.NoError(t, err)
+	assert.Contains(t, string(service),
+		"\nExecStart=/usr/bin/standard-backups --config "+tc.ConfigPath+" backup nightly\n")
+	assert.Contains(t, string(service), "\nProtectSystem=full\n")
+	assert.NotContains(t, string(service), "NoNewPrivileges")
+	timer, err := os.ReadFile(path.Join(outDir, "standard-backups-nightly.timer"))
+	require.NoError(t, err)
+	assert.Contains(t, string(timer), "\nOnCalendar=*-*-* 03:00:00\n")
+	assert.Contains(t, string(timer), "\nPersistent=true\n")
+	assert.Contains(t, string(timer), "\nRandomizedDelaySec=900\n")
+
+	// Regenerating leaves files untouched
+	old := time.Now().Add(-time.Hour)
+	timerPath := path.Join(outDir, "standard-backups-nightly.timer")
+	require.NoError(t, os.Chtimes(timerPath, old, old))
+	generate()
+	stat, err := os.Stat(timerPath)
+	require.NoError(t, err)
+	assert.True(t, stat.ModTime().Equal(old))
+
+	// Units of jobs that lost their schedule get removed
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		jobs:
+			nightly:
+				recipe: bogus
+				backup-to: []
+	`))
+	generate()
+	entries, err = os.ReadDir(outDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGenerateSystemdStdout(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		jobs:
+			nightly:
+				recipe: bogus
+				backup-to: []
+				schedule: "@daily"
+	`))
+	cmd := testutils.StandardBackups(t, "generate", "systemd", "--no-hardening")
+	tc.Apply(cmd)
+	stdout := bytes.NewBufferString("")
+	cmd.Stdout = stdout
+	require.NoError(t, cmd.Run())
+	assert.Regexp(t, `^# standard-backups-nightly.service\n`, stdout.String())
+	assert.Contains(t, stdout.String(), "\n\n# standard-backups-nightly.timer\n")
+	assert.NotContains(t, stdout.String(), "ProtectSystem")
+}
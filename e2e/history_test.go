@@ -0,0 +1,100 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/dotboris/standard-backups/internal/testbackend"
+	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+			Res:      &proto.BackupResponse{Id: "abc123"},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.AddRecipe("broken", testutils.DedentYaml(`
+		version: 1
+		name: broken
+		paths: [/nope]
+		before:
+			shell: sh
+			command: exit 1
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			good:
+				recipe: bogus
+				backup-to: [my-dest]
+			bad:
+				recipe: broken
+				backup-to: [my-dest]
+	`))
+
+	for _, job := range []string{"good", "bad", "good"} {
+		cmd := testutils.StandardBackups(t, "backup", job)
+		tc.Apply(cmd)
+		tb.Apply(cmd)
+		_ = cmd.Run()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "history", "--json")
+		tc.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		require.NoError(t, cmd.Run())
+
+		var records []history.Record
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &records))
+		if assert.Len(t, records, 3) {
+			// Most recent first
+			assert.Equal(t, "good", records[0].Job)
+			assert.Equal(t, history.StatusSuccess, records[0].Status)
+			if assert.Len(t, records[0].Destinations, 1) {
+				assert.Equal(t, "my-dest", records[0].Destinations[0].Destination)
+				assert.Equal(t, "abc123", records[0].Destinations[0].Result.Id)
+			}
+			assert.Equal(t, "bad", records[1].Job)
+			assert.Equal(t, history.StatusFailure, records[1].Status)
+			assert.Contains(t, records[1].Error, "before hook failed")
+			assert.Equal(t, "good", records[2].Job)
+		}
+	})
+
+	t.Run("filtered table", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "history", "bad")
+		tc.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		require.NoError(t, cmd.Run())
+		assert.Regexp(t, `(?m)^\s*bad\s+│\s+failure\s+│.*│\s+0/0\s+│\s+before hook failed`,
+			stdout.String())
+		assert.NotContains(t, stdout.String(), "good")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "history", "--status", "success", "--json")
+		tc.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		require.NoError(t, cmd.Run())
+		var records []history.Record
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &records))
+		assert.Len(t, records, 2)
+	})
+}
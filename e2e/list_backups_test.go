@@ -195,5 +195,5 @@ func TestListBackupsNotImplemented(t *testing.T) {
 	require.Error(t, err)
 	assert.ErrorAs(t, err, &exitError)
 	assert.Equal(t, 1, exitError.ExitCode())
-	assert.Contains(t, stderr.String(), "Error: unhandled command list-backups\n")
+	assert.Contains(t, stderr.String(), "Error: destination my-dest (test) cannot list backups\n")
 }
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -183,6 +184,8 @@ func TestResticBackupForget(t *testing.T) {
 	tc := testutils.NewTestConfig(t)
 	tc.AddBackend("restic", "dist/standard-backups-restic-backend")
 	tc.AddBogusRecipe(t, "bogus")
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
 	tc.WriteConfig(testutils.DedentYaml(fmt.Sprintf(`
 		version: 1
 		secrets:
@@ -198,6 +201,8 @@ func TestResticBackupForget(t *testing.T) {
 						options:
 							group-by: ''
 							keep-last: 1
+							# Lists are passed as repeated flags
+							host: ['%s', other-host]
 					env:
 						RESTIC_PASSWORD: '{{ .Secrets.pass }}'
 			vars:
@@ -223,7 +228,7 @@ func TestResticBackupForget(t *testing.T) {
 			my-job:
 				recipe: bogus
 				backup-to: [simple, vars/a, vars/b]
-	`, simpleRepoDir, varsRepoDir)))
+	`, simpleRepoDir, hostname, varsRepoDir)))
 
 	for range 3 {
 		cmd := testutils.StandardBackups(t, "backup", "my-job")
@@ -390,3 +395,51 @@ func TestResticListBackups(t *testing.T) {
 		})
 	}
 }
+
+func TestResticForgetOptionsSchema(t *testing.T) {
+	validateConfig := func(t *testing.T, forgetOptions string) (string, error) {
+		configPath := path.Join(t.TempDir(), "config.yaml")
+		err := os.WriteFile(configPath, []byte(testutils.DedentYaml(`
+			version: 1
+			destinations:
+				my-dest:
+					backend: restic
+					options:
+						repo: /path/to/repo
+						forget:
+							enable: true
+							options: `+forgetOptions+`
+		`)), 0o600)
+		require.NoError(t, err)
+
+		root := testutils.GetRepoRoot(t)
+		cmd := testutils.StandardBackups(t, "validate-config", "--config", configPath)
+		cmd.Env = append(os.Environ(),
+			fmt.Sprintf("XDG_DATA_DIRS=%s/examples/config/share", root),
+			fmt.Sprintf("XDG_RUNTIME_DIR=%s", t.TempDir()),
+			fmt.Sprintf("XDG_STATE_HOME=%s", t.TempDir()),
+			"XDG_CONFIG_DIRS=",
+		)
+		stderr := bytes.Buffer{}
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+		return stderr.String(), err
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		// host and tag aren't in the schema but they're still passed to restic
+		// forget, lists as repeated flags
+		stderr, err := validateConfig(t, `{ keep-last: 4, host: my-host, tag: [foo, bar] }`)
+		assert.NoError(t, err, stderr)
+	})
+	t.Run("not a count", func(t *testing.T) {
+		stderr, err := validateConfig(t, `{ keep-daily: seven }`)
+		assert.Error(t, err)
+		assert.Contains(t, stderr, "/destinations/my-dest/options/forget/options/keep-daily")
+	})
+	t.Run("not a flag", func(t *testing.T) {
+		stderr, err := validateConfig(t, `{ tag: { foo: bar } }`)
+		assert.Error(t, err)
+		assert.Contains(t, stderr, "/destinations/my-dest/options/forget/options/tag")
+	})
+}
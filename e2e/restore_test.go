@@ -129,5 +129,9 @@ func TestRestoreNotImplemented(t *testing.T) {
 	require.Error(t, err)
 	assert.ErrorAs(t, err, &exitError)
 	assert.Equal(t, 1, exitError.ExitCode())
-	assert.Contains(t, stderr.String(), "Error: unhandled command restore\n")
+	assert.Contains(
+		t,
+		stderr.String(),
+		"Error: destination my-dest (test) cannot restore backups\n",
+	)
 }
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path"
@@ -13,10 +14,10 @@ import (
 
 func TestSecretsPassedToBackend(t *testing.T) {
 	secretFile1 := path.Join(t.TempDir(), "secret1.txt")
-	err := os.WriteFile(secretFile1, []byte("file secret 1"), 0o644)
+	err := os.WriteFile(secretFile1, []byte("file secret 1"), 0o600)
 	require.NoError(t, err)
 	secretFile2 := path.Join(t.TempDir(), "secret2.txt")
-	err = os.WriteFile(secretFile2, []byte("file secret 2\n"), 0o644)
+	err = os.WriteFile(secretFile2, []byte("file secret 2\n"), 0o600)
 	require.NoError(t, err)
 	b := testutils.NewDumpBackend(t)
 	tc := testutils.NewTestConfig(t)
@@ -56,17 +57,17 @@ func TestSecretsPassedToBackend(t *testing.T) {
 		assert.Equal(t, map[string]any{
 			"literal": "supersecret",
 			"file1":   "file secret 1",
-			"file2":   "file secret 2\n",
+			"file2":   "file secret 2",
 		}, b.ReadJsonMap("STANDARD_BACKUPS_OPTIONS"))
 	}
 }
 
 func TestSecretsPassedToBackendWithVarient(t *testing.T) {
 	secretFile1 := path.Join(t.TempDir(), "secret1.txt")
-	err := os.WriteFile(secretFile1, []byte("file secret 1"), 0o644)
+	err := os.WriteFile(secretFile1, []byte("file secret 1"), 0o600)
 	require.NoError(t, err)
 	secretFile2 := path.Join(t.TempDir(), "secret2.txt")
-	err = os.WriteFile(secretFile2, []byte("file secret 2\n"), 0o644)
+	err = os.WriteFile(secretFile2, []byte("file secret 2\n"), 0o600)
 	require.NoError(t, err)
 	b := testutils.NewDumpBackend(t)
 	tc := testutils.NewTestConfig(t)
@@ -107,7 +108,52 @@ func TestSecretsPassedToBackendWithVarient(t *testing.T) {
 		assert.Equal(t, map[string]any{
 			"literal": "supersecret",
 			"file1":   "file secret 1",
-			"file2":   "file secret 2\n",
+			"file2":   "file secret 2",
 		}, b.ReadJsonMap("STANDARD_BACKUPS_OPTIONS"))
 	}
 }
+
+func TestSecretsFromEnvAndCommand(t *testing.T) {
+	b := testutils.NewDumpBackend(t)
+	tc := testutils.NewTestConfig(t)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.AddBackend("test-backend", b.Path)
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		secrets:
+			env:
+				from-env: MY_SECRET
+			command:
+				from-command:
+					shell: bash
+					command: |
+						echo "using $MY_SECRET" >&2
+						echo 'command secret'
+		destinations:
+			my-dest:
+				backend: test-backend
+				options:
+					env: '{{ .Secrets.env }}'
+					command: '{{ .Secrets.command }}'
+		jobs:
+			my-job:
+				recipe: bogus
+				backup-to: [my-dest]
+	`))
+
+	cmd := testutils.StandardBackups(t, "backup", "my-job")
+	tc.Apply(cmd)
+	cmd.Env = append(cmd.Env, "MY_SECRET=env secret")
+	stderr := bytes.NewBufferString("")
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]any{
+			"env":     "env secret",
+			"command": "command secret",
+		}, b.ReadJsonMap("STANDARD_BACKUPS_OPTIONS"))
+		// What the command prints on stderr is redacted
+		assert.Contains(t, stderr.String(), "using ***\n")
+		assert.NotContains(t, stderr.String(), "env secret")
+	}
+}
@@ -0,0 +1,162 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/dotboris/standard-backups/internal/status"
+	"github.com/dotboris/standard-backups/internal/testbackend"
+	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func statusTestConfig(t *testing.T) (*testutils.TestConfig, *testbackend.TestBackend) {
+	tc := testutils.NewTestConfig(t)
+	tb := testbackend.New(t, testbackend.Impl{
+		Backup: testbackend.BackupImpl{
+			BaseImpl: testbackend.BaseImpl{Enable: true},
+		},
+	})
+	tb.AddSelf(tc)
+	tc.AddBogusRecipe(t, "bogus")
+	tc.AddRecipe("broken", testutils.DedentYaml(`
+		version: 1
+		name: broken
+		paths: [/nope]
+		before:
+			shell: sh
+			command: exit 1
+	`))
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			good:
+				recipe: bogus
+				backup-to: [my-dest]
+				max-age: 1h
+			bad:
+				recipe: broken
+				backup-to: [my-dest]
+			never:
+				recipe: bogus
+				backup-to: [my-dest]
+	`))
+	return tc, tb
+}
+
+func TestStatus(t *testing.T) {
+	tc, tb := statusTestConfig(t)
+	for _, job := range []string{"good", "bad"} {
+		cmd := testutils.StandardBackups(t, "backup", job)
+		tc.Apply(cmd)
+		tb.Apply(cmd)
+		_ = cmd.Run()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "status", "--json")
+		tc.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		err := cmd.Run()
+
+		var exitErr *exec.ExitError
+		if assert.ErrorAs(t, err, &exitErr) {
+			assert.Equal(t, 2, exitErr.ExitCode())
+		}
+
+		var reports []map[string]any
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &reports))
+		if assert.Len(t, reports, 3) {
+			assert.Equal(t, "bad", reports[0]["job"])
+			assert.Equal(t, string(status.VerdictFailing), reports[0]["verdict"])
+			assert.Nil(t, reports[0]["last_success"])
+			assert.NotNil(t, reports[0]["last_failure"])
+			assert.Contains(t, reports[0]["last_error"], "before hook failed")
+			assert.Equal(t, "good", reports[1]["job"])
+			assert.Equal(t, string(status.VerdictOk), reports[1]["verdict"])
+			assert.NotNil(t, reports[1]["last_success"])
+			assert.NotNil(t, reports[1]["age_seconds"])
+			assert.Equal(t, float64(3600), reports[1]["max_age_seconds"])
+			assert.Equal(t, "never", reports[2]["job"])
+			assert.Equal(t, string(status.VerdictPending), reports[2]["verdict"])
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		cmd := testutils.StandardBackups(t, "status")
+		tc.Apply(cmd)
+		stdout := bytes.NewBufferString("")
+		cmd.Stdout = stdout
+		stderr := bytes.NewBufferString("")
+		cmd.Stderr = stderr
+		err := cmd.Run()
+
+		var exitErr *exec.ExitError
+		if assert.ErrorAs(t, err, &exitErr) {
+			assert.Equal(t, 2, exitErr.ExitCode())
+		}
+		assert.Regexp(t, `(?m)^\s*bad\s+│\s+FAILING\s+│\s+never\s+│`, stdout.String())
+		assert.Regexp(t, `(?m)^\s*good\s+│\s+OK\s+│.*│\s+never\s+│.*│\s+1h0m0s\s*$`, stdout.String())
+		assert.Regexp(t, `(?m)^\s*never\s+│\s+PENDING\s+│\s+never\s+│\s+never\s+│\s+-\s+│\s+-\s*$`,
+			stdout.String())
+		assert.Contains(t, stderr.String(), "Error: 1 of 3 jobs are not OK\n")
+	})
+}
+
+func TestStatusOk(t *testing.T) {
+	tc, tb := statusTestConfig(t)
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			good:
+				recipe: bogus
+				backup-to: [my-dest]
+				max-age: 1h
+			# Never ran but that's not a problem without a max-age
+			pending:
+				recipe: bogus
+				backup-to: [my-dest]
+	`))
+	cmd := testutils.StandardBackups(t, "backup", "good")
+	tc.Apply(cmd)
+	tb.Apply(cmd)
+	require.NoError(t, cmd.Run())
+
+	cmd = testutils.StandardBackups(t, "status")
+	tc.Apply(cmd)
+	assert.NoError(t, cmd.Run())
+}
+
+func TestStatusStale(t *testing.T) {
+	tc, _ := statusTestConfig(t)
+	tc.WriteConfig(testutils.DedentYaml(`
+		version: 1
+		destinations:
+			my-dest:
+				backend: test
+		jobs:
+			good:
+				recipe: bogus
+				backup-to: [my-dest]
+				max-age: 1h
+	`))
+
+	// The job never ran but it's expected to run every hour
+	cmd := testutils.StandardBackups(t, "status")
+	tc.Apply(cmd)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if assert.ErrorAs(t, err, &exitErr) {
+		assert.Equal(t, 1, exitErr.ExitCode())
+	}
+}
@@ -0,0 +1,43 @@
+// Package atomicfile writes files atomically so that readers never see a
+// partially written file.
+package atomicfile
+
+import (
+	"io"
+	"os"
+	"path"
+)
+
+// Write replaces the file at p with what write writes to it. The content goes
+// to a hidden temporary file in the same directory which is then renamed over
+// p. The directory is created if it doesn't exist.
+func Write(p string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := path.Dir(p)
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+path.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func TestWrite(t *testing.T) {
+	p := path.Join(t.TempDir(), "sub", "file.txt")
+	err := Write(p, 0o640, writeString("hello"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+}
+
+func TestWriteReplaces(t *testing.T) {
+	p := path.Join(t.TempDir(), "file.txt")
+	require.NoError(t, Write(p, 0o644, writeString("old")))
+	require.NoError(t, Write(p, 0o644, writeString("new")))
+
+	content, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
+
+func TestWriteFailureKeepsOldFile(t *testing.T) {
+	d := t.TempDir()
+	p := path.Join(d, "file.txt")
+	require.NoError(t, Write(p, 0o644, writeString("old")))
+
+	err := Write(p, 0o644, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("oops")
+	})
+	assert.EqualError(t, err, "oops")
+
+	content, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+	// The temporary file is cleaned up
+	entries, err := os.ReadDir(d)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -1,18 +1,28 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/lock"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/pkg/proto"
 )
 
 type (
 	backuper interface {
-		Backup(req *proto.BackupRequest) error
+		Backup(
+			ctx context.Context,
+			req *proto.BackupRequest,
+			onProgress proto.ProgressFunc,
+		) (*proto.BackupResponse, error)
 	}
 	backendClientFactory struct{}
 	newBackendClienter   interface {
@@ -20,9 +30,43 @@ type (
 	}
 	backupService struct {
 		backendClientFactory newBackendClienter
+		// OnProgress gets called with the progress events reported by backends
+		// while they perform backups.
+		OnProgress func(job string, destination string, ev proto.ProgressEvent)
+		// sleep waits between retries. Defaults to time.Sleep when nil.
+		sleep func(d time.Duration)
+		// cleanupTimeout is how long the after and on-failure hooks get to run
+		// once the backup is canceled. Defaults to defaultCleanupTimeout when 0.
+		cleanupTimeout time.Duration
+	}
+	// DestinationResult is the outcome of backing up a job to one destination.
+	DestinationResult struct {
+		Destination string
+		// Variant of the destination that was used. Empty when the destination
+		// has no variants.
+		Variant   string
+		Backend   string
+		StartTime time.Time
+		EndTime   time.Time
+		// Nil when the backup failed or when the backend didn't report anything
+		Response *proto.BackupResponse
+		Err      error
+	}
+	// JobResult is the outcome of running a backup job.
+	JobResult struct {
+		Job       string
+		StartTime time.Time
+		EndTime   time.Time
+		// Set when the job was already running and its lock policy is skip
+		Skipped      bool
+		Destinations []DestinationResult
+		// Only set by BackupJobs. Backup returns the error instead.
+		Err error
 	}
 )
 
+const defaultCleanupTimeout = 5 * time.Minute
+
 func (f *backendClientFactory) NewBackendClient(cfg config.Config, name string) (backuper, error) {
 	return proto.NewBackendClient(cfg, name)
 }
@@ -33,16 +77,86 @@ func NewBackupService() backupService {
 	}
 }
 
-func (s *backupService) Backup(cfg config.Config, jobName string) error {
+func (s *backupService) progressHandler(
+	logger *slog.Logger,
+	jobName string,
+	destName string,
+) proto.ProgressFunc {
+	logger = logger.With(slog.String("destination", destName))
+	phase := ""
+	return func(ev proto.ProgressEvent) {
+		if ev.Phase != phase {
+			phase = ev.Phase
+			logger.Info("backup progress", slog.String("phase", ev.Phase))
+		}
+		logger.Debug("backup progress",
+			slog.String("phase", ev.Phase),
+			slog.Float64("percentDone", ev.PercentDone),
+			slog.Int64("filesDone", ev.FilesDone),
+			slog.Int64("totalFiles", ev.TotalFiles),
+			slog.Int64("bytesDone", ev.BytesDone),
+			slog.Int64("totalBytes", ev.TotalBytes),
+			slog.Int64("secondsRemaining", ev.SecondsRemaining),
+		)
+		if s.OnProgress != nil {
+			s.OnProgress(jobName, destName, ev)
+		}
+	}
+}
+
+func logBackupResponse(logger *slog.Logger, res *proto.BackupResponse) {
+	if res == nil {
+		return
+	}
+	logger.Info("backup created",
+		slog.String("id", res.Id),
+		slog.String("time", res.Time),
+		slog.Int64("bytesAdded", res.BytesAdded),
+		slog.Int64("bytesProcessed", res.BytesProcessed),
+		slog.Int64("filesNew", res.FilesNew),
+		slog.Int64("filesChanged", res.FilesChanged),
+		slog.Int64("filesUnmodified", res.FilesUnmodified),
+		slog.Int64("filesProcessed", res.FilesProcessed),
+	)
+	if len(res.Extra) > 0 {
+		logger.Debug("backup created", slog.Any("extra", res.Extra))
+	}
+}
+
+// Backup runs the given job, records the run in the history, exports its
+// metrics and sends notifications. The returned result is always set, even
+// when the backup fails, so that callers can report on what happened.
+func (s *backupService) Backup(
+	ctx context.Context,
+	cfg config.Config,
+	jobName string,
+) (*JobResult, error) {
+	result, err := s.backup(ctx, cfg, jobName)
+	if _, ok := cfg.MainConfig.Jobs[jobName]; ok {
+		recordRun(cfg.MainConfig, result, err)
+		exportMetrics(cfg.MainConfig, result, err)
+		s.notify(ctx, cfg, result, err)
+	}
+	return result, err
+}
+
+func (s *backupService) backup(
+	ctx context.Context,
+	cfg config.Config,
+	jobName string,
+) (*JobResult, error) {
 	startTime := time.Now()
+	result := &JobResult{Job: jobName, StartTime: startTime}
+	defer func() { result.EndTime = time.Now() }()
+
 	job, ok := cfg.MainConfig.Jobs[jobName]
 	if !ok {
-		return fmt.Errorf("could not find a job named %s", jobName)
+		return result, fmt.Errorf("could not find a job named %s", jobName)
 	}
 
 	recipe, err := cfg.GetRecipeManifest(job.Recipe)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	logger := slog.With(
@@ -50,59 +164,80 @@ func (s *backupService) Backup(cfg config.Config, jobName string) error {
 		slog.String("recipe", recipe.Name),
 	)
 
+	l, err := lockJob(ctx, cfg.MainConfig, logger, jobName, job)
+	var lockedErr *lock.LockedError
+	if errors.As(err, &lockedErr) {
+		if config.GetLock(job).Policy == config.LockPolicySkip {
+			logger.Info("skipping backup, job is already running", slog.Int("pid", lockedErr.Pid))
+			result.Skipped = true
+			return result, nil
+		}
+		return result, fmt.Errorf("job %s is already running: %w", jobName, err)
+	} else if err != nil {
+		return result, fmt.Errorf("failed to lock job %s: %w", jobName, err)
+	}
+	defer func() {
+		err := l.Release()
+		if err != nil {
+			logger.Warn("failed to release job lock", slog.Any("error", err))
+		}
+	}()
+
+	// The after and on-failure hooks still run when the backup gets canceled
+	// (ex: SIGINT) so that they can clean up. They only get a limited amount of
+	// time to do so.
+	cleanupTimeout := s.cleanupTimeout
+	if cleanupTimeout == 0 {
+		cleanupTimeout = defaultCleanupTimeout
+	}
+	cleanupCtx, cancelCleanup := process.WithCleanupTimeout(ctx, cleanupTimeout)
+	defer cancelCleanup()
+
 	var errs error
+	hc := hookContext{
+		job:          jobName,
+		recipe:       *recipe,
+		destinations: job.BackupTo,
+		startTime:    startTime,
+	}
 
 	if recipe.Before != nil {
 		logger.Info("running before hook", slog.Any("hook", recipe.Before))
-		err := runHook(*recipe.Before)
+		err := runHook(ctx, *recipe.Before, hc.env(hookPhaseBefore, time.Now()))
 		if err != nil {
 			errs = errors.Join(errs, fmt.Errorf("before hook failed: %w", err))
 		}
 	}
 
 	if errs == nil {
-		for _, destName := range job.BackupTo {
-			dest, ref, err := cfg.MainConfig.GetDestination(destName)
-			if err != nil {
-				errs = errors.Join(errs, err)
-				continue
-			}
-			client, err := s.backendClientFactory.NewBackendClient(cfg, dest.Backend)
-			if err != nil {
-				errs = errors.Join(
-					errs,
-					fmt.Errorf(
-						"failed to create backup client for destination named %s: %w",
-						destName,
-						err,
-					),
-				)
-				continue
-			}
-			logger.Info("performing backup",
-				slog.String("destination", destName),
-				slog.String("backend", dest.Backend))
-			err = client.Backup(
-				&proto.BackupRequest{
-					Paths:           recipe.Paths,
-					Exclude:         recipe.Exclude,
-					DestinationName: ref.Name,
-					VariantName:     ref.Variant,
-					JobName:         jobName,
-					RawOptions:      dest.Options,
-				},
-			)
-			if err != nil {
-				errs = errors.Join(errs,
-					fmt.Errorf("failed to backup destination named %s: %w", destName, err))
-				continue
+		// The job's timeout only covers destinations. The after hook must run
+		// regardless so that it can undo what the before hook did.
+		destCtx, cancel := process.WithTimeout(ctx, job.Timeout)
+		result.Destinations = s.backupDestinations(
+			destCtx,
+			cfg,
+			logger,
+			jobName,
+			recipe,
+			job.BackupTo,
+			cfg.MainConfig.GetJobParallelism(job),
+		)
+		cancel()
+		if ctx.Err() != nil {
+			logger.Warn("backup canceled", slog.Any("cause", context.Cause(ctx)))
+		}
+		for _, destResult := range result.Destinations {
+			if destResult.Err != nil {
+				errs = errors.Join(errs, destResult.Err)
 			}
 		}
 	}
 
+	hc.results = result.Destinations
+	hc.err = errs
 	if recipe.After != nil {
 		logger.Info("running after hook", slog.Any("hook", recipe.After))
-		err := runHook(*recipe.After)
+		err := runHook(cleanupCtx, *recipe.After, hc.env(hookPhaseAfter, time.Now()))
 		if err != nil {
 			errs = errors.Join(errs, fmt.Errorf("after hook failed: %w", err))
 		}
@@ -112,7 +247,7 @@ func (s *backupService) Backup(cfg config.Config, jobName string) error {
 		logger.Info("completed backup", slog.Duration("duration", time.Since(startTime)))
 		if job.OnSuccess != nil {
 			logger.Info("running on-success hook", slog.Any("hook", job.OnSuccess))
-			err := runHook(*job.OnSuccess)
+			err := runHook(ctx, *job.OnSuccess, hc.env(hookPhaseOnSuccess, time.Now()))
 			if err != nil {
 				errs = errors.Join(errs, fmt.Errorf("on-success hook failed: %w", err))
 			}
@@ -127,12 +262,237 @@ func (s *backupService) Backup(cfg config.Config, jobName string) error {
 		)
 		if job.OnFailure != nil {
 			logger.Info("running on-failure hook", slog.Any("hook", job.OnFailure))
-			err := runHook(*job.OnFailure)
+			hc.err = errs
+			err := runHook(cleanupCtx, *job.OnFailure, hc.env(hookPhaseOnFailure, time.Now()))
 			if err != nil {
 				errs = errors.Join(errs, fmt.Errorf("on-failure hook failed: %w", err))
 			}
 		}
 	}
 
-	return errs
+	return result, errs
+}
+
+// BackupJobs runs the given jobs with at most parallelism jobs running at the
+// same time. Results are in the same order as jobNames. The returned error
+// joins the errors of all the jobs that failed.
+func (s *backupService) BackupJobs(
+	ctx context.Context,
+	cfg config.Config,
+	jobNames []string,
+	parallelism int,
+) ([]JobResult, error) {
+	res := make([]JobResult, len(jobNames))
+	backupJob := func(i int) {
+		result, err := s.Backup(ctx, cfg, jobNames[i])
+		res[i] = *result
+		res[i].Err = err
+	}
+	if parallelism <= 1 {
+		for i := range jobNames {
+			backupJob(i)
+		}
+	} else {
+		slog.Debug("running jobs in parallel", slog.Int("parallelism", parallelism))
+		sem := make(chan struct{}, parallelism)
+		var wg sync.WaitGroup
+		for i := range jobNames {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				backupJob(i)
+			}()
+		}
+		wg.Wait()
+	}
+
+	var errs error
+	for _, r := range res {
+		if r.Err != nil {
+			errs = errors.Join(errs, fmt.Errorf("job %s failed: %w", r.Job, r.Err))
+		}
+	}
+	return res, errs
+}
+
+// lockJob takes the lock that prevents multiple instances of the same job from
+// running at the same time. With the wait policy, it waits for the lock to be
+// released up to the configured timeout.
+func lockJob(
+	ctx context.Context,
+	mc config.MainConfig,
+	logger *slog.Logger,
+	jobName string,
+	job config.JobConfigV1,
+) (*lock.Lock, error) {
+	p := path.Join(mc.GetRuntimeDir(), "locks", jobName+".lock")
+	l, err := lock.TryAcquire(p)
+	var lockedErr *lock.LockedError
+	settings := config.GetLock(job)
+	if !errors.As(err, &lockedErr) || settings.Policy != config.LockPolicyWait {
+		return l, err
+	}
+
+	logger.Info("job is already running, waiting for it to complete",
+		slog.Int("pid", lockedErr.Pid),
+		slog.Duration("timeout", settings.Timeout))
+	ctx, cancel := process.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+	return lock.Wait(ctx, p)
+}
+
+// backupDestinations backs up to every destination with at most parallelism
+// backups running at the same time. Results are in the same order as
+// destNames regardless of the order in which the backups complete.
+func (s *backupService) backupDestinations(
+	ctx context.Context,
+	cfg config.Config,
+	logger *slog.Logger,
+	jobName string,
+	recipe *config.RecipeManifestV1,
+	destNames []string,
+	parallelism int,
+) []DestinationResult {
+	res := make([]DestinationResult, len(destNames))
+	if parallelism <= 1 {
+		for i, destName := range destNames {
+			res[i] = s.backupDestination(ctx, cfg, logger, jobName, recipe, destName)
+		}
+		return res
+	}
+
+	logger.Debug("backing up destinations in parallel", slog.Int("parallelism", parallelism))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, destName := range destNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res[i] = s.backupDestination(ctx, cfg, logger, jobName, recipe, destName)
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+func (s *backupService) backupDestination(
+	ctx context.Context,
+	cfg config.Config,
+	logger *slog.Logger,
+	jobName string,
+	recipe *config.RecipeManifestV1,
+	destName string,
+) (res DestinationResult) {
+	res = DestinationResult{Destination: destName, StartTime: time.Now()}
+	defer func() { res.EndTime = time.Now() }()
+
+	dest, ref, err := cfg.MainConfig.GetDestination(destName)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Variant = ref.Variant
+	res.Backend = dest.Backend
+	client, err := s.backendClientFactory.NewBackendClient(cfg, dest.Backend)
+	if err != nil {
+		res.Err = fmt.Errorf(
+			"failed to create backup client for destination named %s: %w",
+			destName,
+			err,
+		)
+		return res
+	}
+	req := newBackupRequest(jobName, recipe, dest, ref)
+	retry := config.GetRetry(cfg.MainConfig.Jobs[jobName], *dest)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && ctx.Err() != nil {
+			// The job timed out while waiting to retry
+			res.Err = fmt.Errorf(
+				"failed to backup destination named %s: gave up after %d attempts: %w",
+				destName,
+				attempt-1,
+				err,
+			)
+			return res
+		} else if ctx.Err() != nil {
+			// The job timed out while waiting on other destinations
+			res.Err = fmt.Errorf("failed to backup destination named %s: %w",
+				destName, context.Cause(ctx))
+			return res
+		}
+		logger.Info("performing backup",
+			slog.String("destination", destName),
+			slog.String("backend", dest.Backend),
+			slog.Int("attempt", attempt))
+		attemptCtx, cancel := process.WithTimeout(ctx, dest.Timeout)
+		res.Response, err = client.Backup(
+			attemptCtx,
+			req,
+			s.progressHandler(logger, jobName, destName),
+		)
+		cancel()
+		if err == nil {
+			break
+		}
+		// No point in retrying once the whole job has timed out
+		if attempt >= retry.Attempts || ctx.Err() != nil {
+			if attempt > 1 {
+				err = fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+			}
+			res.Err = fmt.Errorf("failed to backup destination named %s: %w", destName, err)
+			return res
+		}
+		delay := retryDelay(retry, attempt, rand.Float64())
+		logger.Warn("backup attempt failed, retrying",
+			slog.String("destination", destName),
+			slog.Int("attempt", attempt),
+			slog.Int("attempts", retry.Attempts),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		s.doSleep(ctx, delay)
+	}
+	logBackupResponse(logger.With(slog.String("destination", destName)), res.Response)
+	return res
+}
+
+func newBackupRequest(
+	jobName string,
+	recipe *config.RecipeManifestV1,
+	dest *config.DestinationConfigV1,
+	ref *config.DestinationRef,
+) *proto.BackupRequest {
+	return &proto.BackupRequest{
+		Paths:           recipe.Paths,
+		Exclude:         recipe.Exclude,
+		DestinationName: ref.Name,
+		VariantName:     ref.Variant,
+		JobName:         jobName,
+		RawOptions:      dest.Options,
+	}
+}
+
+// retryDelay returns how long to wait after the given failed attempt. rnd is a
+// random number in [0, 1) used to apply jitter.
+func retryDelay(retry config.RetryV1, attempt int, rnd float64) time.Duration {
+	delay := retry.Delay(attempt)
+	jitter := float64(delay) * retry.Jitter * (2*rnd - 1)
+	return delay + time.Duration(jitter)
+}
+
+// doSleep waits for d or until ctx is done, whichever comes first.
+func (s *backupService) doSleep(ctx context.Context, d time.Duration) {
+	if s.sleep != nil {
+		s.sleep(d)
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
@@ -1,19 +1,25 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/lock"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/internal/testutils"
 	"github.com/dotboris/standard-backups/pkg/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBackupSimple(t *testing.T) {
@@ -22,6 +28,7 @@ func TestBackupSimple(t *testing.T) {
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
 			client.EXPECT().Backup(
+				mock.Anything,
 				&proto.BackupRequest{
 					Paths:           []string{"path1", "path2"},
 					DestinationName: "dest",
@@ -31,12 +38,14 @@ func TestBackupSimple(t *testing.T) {
 						"biz": 42,
 					},
 				},
-			).Return(nil)
+				mock.Anything,
+			).Return(nil, nil)
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name:  "back-me-up",
@@ -65,18 +74,638 @@ func TestBackupSimple(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestBackupProgress(t *testing.T) {
+	event := proto.ProgressEvent{
+		Phase:       "backup",
+		PercentDone: 0.5,
+		FilesDone:   21,
+		TotalFiles:  42,
+	}
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(func(
+					ctx context.Context,
+					req *proto.BackupRequest,
+					onProgress proto.ProgressFunc,
+				) (*proto.BackupResponse, error) {
+					onProgress(event)
+					return nil, nil
+				})
+			return client, nil
+		})
+	type received struct {
+		job         string
+		destination string
+		event       proto.ProgressEvent
+	}
+	events := []received{}
+	svc := backupService{
+		backendClientFactory: fac,
+		OnProgress: func(job string, destination string, ev proto.ProgressEvent) {
+			events = append(events, received{job, destination, ev})
+		},
+	}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"dest"}},
+				},
+			},
+		},
+		"my-job",
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []received{{"my-job", "dest", event}}, events)
+	}
+}
+
+func TestBackupResult(t *testing.T) {
+	response := &proto.BackupResponse{
+		Id:             "abc123",
+		BytesAdded:     42,
+		FilesProcessed: 3,
+	}
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "good-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(response, nil)
+			return client, nil
+		})
+	expectedErr := errors.New("oops")
+	fac.EXPECT().NewBackendClient(mock.Anything, "bad-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, expectedErr)
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+
+	res, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"good": {Backend: "good-backend"},
+					"bad":  {Backend: "bad-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"good", "bad"}},
+				},
+			},
+		},
+		"my-job",
+	)
+	assert.ErrorIs(t, err, expectedErr)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, "my-job", res.Job)
+		assert.False(t, res.EndTime.Before(res.StartTime))
+		assert.False(t, res.EndTime.IsZero())
+		if assert.Len(t, res.Destinations, 2) {
+			assert.Equal(t, "good", res.Destinations[0].Destination)
+			assert.Equal(t, "good-backend", res.Destinations[0].Backend)
+			assert.Equal(t, response, res.Destinations[0].Response)
+			assert.NoError(t, res.Destinations[0].Err)
+			assert.False(t, res.Destinations[0].EndTime.IsZero())
+			assert.Equal(t, "bad", res.Destinations[1].Destination)
+			assert.Nil(t, res.Destinations[1].Response)
+			assert.ErrorIs(t, res.Destinations[1].Err, expectedErr)
+		}
+	}
+}
+
+func TestBackupParallel(t *testing.T) {
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	expectedErr := errors.New("oops")
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(func(
+					ctx context.Context,
+					req *proto.BackupRequest,
+					onProgress proto.ProgressFunc,
+				) (*proto.BackupResponse, error) {
+					started <- req.DestinationName
+					<-release
+					if req.DestinationName == "dest2" {
+						return nil, expectedErr
+					}
+					return &proto.BackupResponse{Id: req.DestinationName}, nil
+				})
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+
+	done := make(chan struct{})
+	var res *JobResult
+	var err error
+	go func() {
+		defer close(done)
+		res, err = svc.Backup(
+			context.Background(),
+			config.Config{
+				Recipes: []config.RecipeManifestV1{{Name: "r"}},
+				MainConfig: config.MainConfig{
+					Destinations: map[string]config.DestinationConfigV1{
+						"dest1": {Backend: "the-backend"},
+						"dest2": {Backend: "the-backend"},
+						"dest3": {Backend: "the-backend"},
+					},
+					Jobs: map[string]config.JobConfigV1{
+						"my-job": {
+							Recipe:      "r",
+							BackupTo:    []string{"dest1", "dest2", "dest3"},
+							Parallelism: 2,
+						},
+					},
+				},
+			},
+			"my-job",
+		)
+	}()
+
+	<-started
+	<-started
+	select {
+	case dest := <-started:
+		t.Errorf("backup to %s started while 2 others were running", dest)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+
+	assert.ErrorIs(t, err, expectedErr)
+	if assert.Len(t, res.Destinations, 3) {
+		assert.Equal(t, &proto.BackupResponse{Id: "dest1"}, res.Destinations[0].Response)
+		assert.ErrorIs(t, res.Destinations[1].Err, expectedErr)
+		assert.Equal(t, &proto.BackupResponse{Id: "dest3"}, res.Destinations[2].Response)
+	}
+}
+
+func TestBackupRetry(t *testing.T) {
+	attempts := 0
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(func(
+					ctx context.Context,
+					req *proto.BackupRequest,
+					onProgress proto.ProgressFunc,
+				) (*proto.BackupResponse, error) {
+					attempts++
+					if attempts < 3 {
+						return nil, errors.New("oops")
+					}
+					return &proto.BackupResponse{Id: "abc"}, nil
+				})
+			return client, nil
+		})
+	sleeps := []time.Duration{}
+	svc := backupService{
+		backendClientFactory: fac,
+		sleep:                func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+
+	res, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {
+						Recipe:   "r",
+						BackupTo: []string{"dest"},
+						Retry: &config.RetryV1{
+							Attempts:     3,
+							InitialDelay: time.Second,
+						},
+					},
+				},
+			},
+		},
+		"my-job",
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+		assert.Equal(t, &proto.BackupResponse{Id: "abc"}, res.Destinations[0].Response)
+	}
+}
+
+func TestBackupRetryGiveUp(t *testing.T) {
+	expectedErr := errors.New("oops")
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, expectedErr).Times(2)
+			return client, nil
+		})
+	sleeps := []time.Duration{}
+	svc := backupService{
+		backendClientFactory: fac,
+		sleep:                func(d time.Duration) { sleeps = append(sleeps, d) },
+	}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {
+						Backend: "the-backend",
+						// Takes precedence over the job's retry policy
+						Retry: &config.RetryV1{Attempts: 2, InitialDelay: time.Minute},
+					},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {
+						Recipe:   "r",
+						BackupTo: []string{"dest"},
+						Retry:    &config.RetryV1{Attempts: 5},
+					},
+				},
+			},
+		},
+		"my-job",
+	)
+	assert.ErrorIs(t, err, expectedErr)
+	assert.ErrorContains(t, err, "gave up after 2 attempts")
+	assert.Equal(t, []time.Duration{time.Minute}, sleeps)
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	retry := config.RetryV1{
+		InitialDelay: 10 * time.Second,
+		MaxDelay:     time.Minute,
+		Multiplier:   2,
+		Jitter:       0.5,
+	}
+	assert.Equal(t, 5*time.Second, retryDelay(retry, 1, 0))
+	assert.Equal(t, 10*time.Second, retryDelay(retry, 1, 0.5))
+	assert.Equal(t, 20*time.Second, retryDelay(retry, 2, 0.5))
+	assert.Equal(t, 27*time.Second, retryDelay(retry, 2, 0.85))
+}
+
+// blockUntilDone mimics a backend that hangs until it gets stopped
+func blockUntilDone(
+	ctx context.Context,
+	req *proto.BackupRequest,
+	onProgress proto.ProgressFunc,
+) (*proto.BackupResponse, error) {
+	<-ctx.Done()
+	return nil, process.Err(ctx, errors.New("signal: terminated"))
+}
+
+func TestBackupDestinationTimeout(t *testing.T) {
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(blockUntilDone)
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend", Timeout: 50 * time.Millisecond},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"dest"}},
+				},
+			},
+		},
+		"my-job",
+	)
+	var timeoutErr *process.TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	}
+}
+
+func TestBackupJobTimeoutRunsAfterHook(t *testing.T) {
+	d := t.TempDir()
+	afterFile := path.Join(d, "after.txt")
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(blockUntilDone)
+			return client, nil
+		})
+	svc := backupService{
+		backendClientFactory: fac,
+		sleep:                func(d time.Duration) {},
+	}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{
+				Name: "r",
+				After: &config.HookV1{
+					Shell:   "sh",
+					Command: fmt.Sprintf("echo after > %s", afterFile),
+				},
+			}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {
+						Recipe:   "r",
+						BackupTo: []string{"dest"},
+						Timeout:  50 * time.Millisecond,
+						// Retries stop once the job times out
+						Retry: &config.RetryV1{Attempts: 10},
+					},
+				},
+			},
+		},
+		"my-job",
+	)
+	var timeoutErr *process.TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	}
+	assert.NotContains(t, err.Error(), "gave up")
+	assert.FileExists(t, afterFile)
+}
+
+func TestBackupCanceledRunsCleanupHooks(t *testing.T) {
+	d := t.TempDir()
+	afterFile := path.Join(d, "after.txt")
+	onFailureFile := path.Join(d, "on-failure.txt")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				RunAndReturn(func(
+					ctx context.Context,
+					req *proto.BackupRequest,
+					onProgress proto.ProgressFunc,
+				) (*proto.BackupResponse, error) {
+					cancel(&process.SignalError{Signal: syscall.SIGTERM})
+					return blockUntilDone(ctx, req, onProgress)
+				})
+			return client, nil
+		})
+	svc := backupService{
+		backendClientFactory: fac,
+		sleep:                func(d time.Duration) {},
+	}
+
+	_, err := svc.Backup(
+		ctx,
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{
+				Name: "r",
+				After: &config.HookV1{
+					Shell:   "sh",
+					Command: fmt.Sprintf("echo after > %s", afterFile),
+				},
+			}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {
+						Recipe:   "r",
+						BackupTo: []string{"dest"},
+						OnFailure: &config.HookV1{
+							Shell:   "sh",
+							Command: fmt.Sprintf("echo on-failure > %s", onFailureFile),
+						},
+						Retry: &config.RetryV1{Attempts: 10},
+					},
+				},
+			},
+		},
+		"my-job",
+	)
+	var signalErr *process.SignalError
+	if assert.ErrorAs(t, err, &signalErr) {
+		assert.Equal(t, syscall.SIGTERM, signalErr.Signal)
+	}
+	assert.NotContains(t, err.Error(), "gave up")
+	assert.FileExists(t, afterFile)
+	assert.FileExists(t, onFailureFile)
+}
+
+func TestBackupCanceledCleanupTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc := backupService{cleanupTimeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	_, err := svc.Backup(
+		ctx,
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{
+				Name:  "r",
+				After: &config.HookV1{Shell: "sh", Command: "sleep 60"},
+			}},
+			MainConfig: config.MainConfig{
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r"},
+				},
+			},
+		},
+		"my-job",
+	)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	var timeoutErr *process.TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+	}
+	assert.ErrorContains(t, err, "after hook failed")
+}
+
+func TestBackupJobs(t *testing.T) {
+	for _, parallelism := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallelism=%d", parallelism), func(t *testing.T) {
+			svc := backupService{}
+			res, err := svc.BackupJobs(
+				context.Background(),
+				config.Config{
+					Recipes: []config.RecipeManifestV1{
+						{Name: "good"},
+						{
+							Name:   "bad",
+							Before: &config.HookV1{Shell: "sh", Command: "exit 1"},
+						},
+					},
+					MainConfig: config.MainConfig{
+						Jobs: map[string]config.JobConfigV1{
+							"job-1": {Recipe: "good"},
+							"job-2": {Recipe: "bad"},
+							"job-3": {Recipe: "good"},
+						},
+					},
+				},
+				[]string{"job-1", "job-2", "job-3"},
+				parallelism,
+			)
+			assert.ErrorContains(t, err, "job job-2 failed: before hook failed")
+			if assert.Len(t, res, 3) {
+				assert.Equal(t, "job-1", res[0].Job)
+				assert.NoError(t, res[0].Err)
+				assert.Equal(t, "job-2", res[1].Job)
+				assert.ErrorContains(t, res[1].Err, "before hook failed")
+				assert.Equal(t, "job-3", res[2].Job)
+				assert.NoError(t, res[2].Err)
+			}
+		})
+	}
+}
+
+func lockTestConfig(runtimeDir string, lock *config.LockV1) config.Config {
+	return config.Config{
+		Recipes: []config.RecipeManifestV1{{Name: "r"}},
+		MainConfig: config.MainConfig{
+			RuntimeDir: runtimeDir,
+			Jobs: map[string]config.JobConfigV1{
+				"my-job": {Recipe: "r", Lock: lock},
+			},
+		},
+	}
+}
+
+func holdJobLock(t *testing.T, runtimeDir string) *lock.Lock {
+	t.Helper()
+	l, err := lock.TryAcquire(path.Join(runtimeDir, "locks", "my-job.lock"))
+	require.NoError(t, err)
+	return l
+}
+
+func TestBackupLockFail(t *testing.T) {
+	runtimeDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+
+	res, err := svc.Backup(context.Background(), lockTestConfig(runtimeDir, nil), "my-job")
+	var lockedErr *lock.LockedError
+	if assert.ErrorAs(t, err, &lockedErr) {
+		assert.Equal(t, os.Getpid(), lockedErr.Pid)
+	}
+	assert.ErrorContains(t, err, "job my-job is already running")
+	assert.False(t, res.Skipped)
+}
+
+func TestBackupLockSkip(t *testing.T) {
+	runtimeDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+
+	res, err := svc.Backup(
+		context.Background(),
+		lockTestConfig(runtimeDir, &config.LockV1{Policy: config.LockPolicySkip}),
+		"my-job",
+	)
+	assert.NoError(t, err)
+	assert.True(t, res.Skipped)
+}
+
+func TestBackupLockWait(t *testing.T) {
+	runtimeDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l.Release()
+	}()
+	svc := backupService{}
+
+	res, err := svc.Backup(
+		context.Background(),
+		lockTestConfig(runtimeDir, &config.LockV1{Policy: config.LockPolicyWait}),
+		"my-job",
+	)
+	assert.NoError(t, err)
+	assert.False(t, res.Skipped)
+}
+
+func TestBackupLockWaitTimeout(t *testing.T) {
+	runtimeDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+
+	_, err := svc.Backup(
+		context.Background(),
+		lockTestConfig(runtimeDir, &config.LockV1{
+			Policy:  config.LockPolicyWait,
+			Timeout: 50 * time.Millisecond,
+		}),
+		"my-job",
+	)
+	var timeoutErr *process.TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	assert.ErrorContains(t, err, "job my-job is already running")
+}
+
+func TestBackupReleasesLock(t *testing.T) {
+	runtimeDir := t.TempDir()
+	svc := backupService{}
+
+	_, err := svc.Backup(context.Background(), lockTestConfig(runtimeDir, nil), "my-job")
+	require.NoError(t, err)
+	_, err = svc.Backup(context.Background(), lockTestConfig(runtimeDir, nil), "my-job")
+	assert.NoError(t, err)
+}
+
 func TestBackupBackupError(t *testing.T) {
 	expectedErr := errors.New("oops")
 	fac := newMockNewBackendClienter(t)
 	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
-			client.EXPECT().Backup(mock.Anything).Return(expectedErr)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, expectedErr)
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "bogus",
@@ -100,13 +729,74 @@ func TestBackupBackupError(t *testing.T) {
 	assert.ErrorIs(t, err, expectedErr)
 }
 
+func TestBackupHooksEnv(t *testing.T) {
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "b1").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, errors.New("disk full"))
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+
+	d := t.TempDir()
+	hooksLog := path.Join(d, "hooks.log")
+	logEnv := &config.HookV1{
+		Shell: "bash",
+		Command: fmt.Sprintf(
+			`echo "$STANDARD_BACKUPS_HOOK $STANDARD_BACKUPS_JOB_NAME $STANDARD_BACKUPS_RECIPE_NAME [$STANDARD_BACKUPS_PATHS] [$STANDARD_BACKUPS_DESTINATION_STATUSES] [$STANDARD_BACKUPS_ERROR]" >> %s`,
+			hooksLog,
+		),
+	}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{
+				Name:   "r",
+				Paths:  []string{"/data"},
+				Before: logEnv,
+				After:  logEnv,
+			}},
+			MainConfig: config.MainConfig{
+				Destinations: map[string]config.DestinationConfigV1{
+					"d1": {Backend: "b1"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"do-it": {
+						Recipe:    "r",
+						BackupTo:  []string{"d1"},
+						OnFailure: logEnv,
+					},
+				},
+			},
+		},
+		"do-it",
+	)
+
+	if assert.Error(t, err) {
+		log, err := os.ReadFile(hooksLog)
+		if assert.NoError(t, err) {
+			assert.Equal(t,
+				testutils.Dedent(`
+					before do-it r [/data] [] []
+					after do-it r [/data] [d1=failure] [failed to backup destination named d1: disk full]
+					on-failure do-it r [] [d1=failure] [failed to backup destination named d1: disk full]
+				`),
+				strings.Trim(string(log), "\n"),
+			)
+		}
+	}
+}
+
 func TestBackupHooksSuccess(t *testing.T) {
 	fac := newMockNewBackendClienter(t)
 	for _, name := range []string{"b1", "b2"} {
 		fac.EXPECT().NewBackendClient(mock.Anything, name).
 			RunAndReturn(func(c config.Config, s string) (backuper, error) {
 				client := newMockBackuper(t)
-				client.EXPECT().Backup(mock.Anything).Return(nil)
+				client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 				return client, nil
 			})
 	}
@@ -115,7 +805,8 @@ func TestBackupHooksSuccess(t *testing.T) {
 	d := t.TempDir()
 	hooksLog := path.Join(d, "hooks.log")
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -177,7 +868,9 @@ func TestBackupHooksFailure(t *testing.T) {
 		fac.EXPECT().NewBackendClient(mock.Anything, name).
 			RunAndReturn(func(c config.Config, s string) (backuper, error) {
 				client := newMockBackuper(t)
-				client.EXPECT().Backup(mock.Anything).Return(errors.New("oops"))
+				client.EXPECT().
+					Backup(mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, errors.New("oops"))
 				return client, nil
 			})
 	}
@@ -186,7 +879,8 @@ func TestBackupHooksFailure(t *testing.T) {
 	d := t.TempDir()
 	hooksLog := path.Join(d, "hooks.log")
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -245,7 +939,8 @@ func TestBackupBeforeHookError(t *testing.T) {
 	fac := newMockNewBackendClienter(t)
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -283,7 +978,8 @@ func TestBackupRunAfterHookOnBeforeError(t *testing.T) {
 
 	outPath := path.Join(t.TempDir(), "out.txt")
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -326,12 +1022,13 @@ func TestBackupAfterHookError(t *testing.T) {
 	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
-			client.EXPECT().Backup(mock.Anything).Return(nil)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -368,12 +1065,13 @@ func TestBackupOnSuccessHookError(t *testing.T) {
 	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
-			client.EXPECT().Backup(mock.Anything).Return(nil)
+			client.EXPECT().Backup(mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -410,12 +1108,15 @@ func TestBackupOnFailureHookError(t *testing.T) {
 	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
-			client.EXPECT().Backup(mock.Anything).Return(errors.New("oops"))
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, errors.New("oops"))
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -452,12 +1153,15 @@ func TestBackupBackupAndHooksError(t *testing.T) {
 	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 		RunAndReturn(func(c config.Config, s string) (backuper, error) {
 			client := newMockBackuper(t)
-			client.EXPECT().Backup(mock.Anything).Return(errors.New("oops"))
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, errors.New("oops"))
 			return client, nil
 		})
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -503,7 +1207,8 @@ func TestBackupOnlyHooksError(t *testing.T) {
 	fac := newMockNewBackendClienter(t)
 	svc := backupService{backendClientFactory: fac}
 
-	err := svc.Backup(
+	_, err := svc.Backup(
+		context.Background(),
 		config.Config{
 			Recipes: []config.RecipeManifestV1{{
 				Name: "r",
@@ -584,7 +1289,10 @@ func TestBackupOnFailureCalledOnError(t *testing.T) {
 			fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
 				RunAndReturn(func(c config.Config, s string) (backuper, error) {
 					client := newMockBackuper(t)
-					client.EXPECT().Backup(mock.Anything).Maybe().Return(nil)
+					client.EXPECT().
+						Backup(mock.Anything, mock.Anything, mock.Anything).
+						Maybe().
+						Return(nil, nil)
 					return client, nil
 				}).Maybe()
 			svc := backupService{backendClientFactory: fac}
@@ -596,7 +1304,8 @@ func TestBackupOnFailureCalledOnError(t *testing.T) {
 				Command: fmt.Sprintf("echo hello from on-failure > %s", outFile),
 			}
 
-			err := svc.Backup(
+			_, err := svc.Backup(
+				context.Background(),
 				config.Config{
 					Recipes: []config.RecipeManifestV1{{
 						Name:   "r",
@@ -9,7 +9,6 @@ import (
 	"path"
 	"strings"
 
-	"github.com/go-viper/mapstructure/v2"
 	"github.com/goccy/go-yaml"
 	"github.com/santhosh-tekuri/jsonschema/v6"
 )
@@ -30,6 +29,9 @@ var (
 			"description":      map[string]any{"type": "string"},
 			"bin":              map[string]any{"type": "string"},
 			"protocol-version": map[string]any{"enum": []any{1}},
+			// Either an inline JSON schema or a path to a file containing one.
+			// Relative paths are resolved from the manifest's directory.
+			"options-schema": map[string]any{"type": []any{"object", "string"}},
 		},
 	}
 	backendManifestV1Schema jsonschema.Schema
@@ -63,6 +65,9 @@ type BackendManifestV1 struct {
 	Description     string `mapstructure:"description"`
 	Bin             string `mapstructure:"bin"`
 	ProtocolVersion int    `mapstructure:"protocol-version"`
+	// OptionsSchema is a JSON schema that destination options are validated
+	// against. Nil means that any options are accepted.
+	OptionsSchema map[string]any `mapstructure:"options-schema"`
 }
 
 func LoadBackendManifests(dirs []string) ([]BackendManifestV1, error) {
@@ -110,12 +115,43 @@ func loadBackendManifest(path string) (*BackendManifestV1, error) {
 		return nil, fmt.Errorf("backend manifest %s is invalid: %w", path, err)
 	}
 
+	if schemaPath, ok := rawManifest["options-schema"].(string); ok {
+		schema, err := loadOptionsSchemaFile(path, schemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load options schema of backend manifest %s: %w",
+				path, err)
+		}
+		rawManifest["options-schema"] = schema
+	}
+
 	var res BackendManifestV1
-	err = mapstructure.Decode(rawManifest, &res)
+	err = decode(rawManifest, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode backend manifest %s: %w", path, err)
 	}
 	res.Path = path
 
+	_, err = res.compileOptionsSchema()
+	if err != nil {
+		return nil, fmt.Errorf("backend manifest %s has an invalid options schema: %w", path, err)
+	}
+
 	return &res, nil
 }
+
+func loadOptionsSchemaFile(manifestPath string, schemaPath string) (map[string]any, error) {
+	if !path.IsAbs(schemaPath) {
+		schemaPath = path.Join(path.Dir(manifestPath), schemaPath)
+	}
+	bytes, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, this handles both
+	res := map[string]any{}
+	err = yaml.Unmarshal(bytes, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", schemaPath, err)
+	}
+	return res, nil
+}
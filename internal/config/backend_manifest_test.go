@@ -183,3 +183,90 @@ func TestLoadBackendManifestsInvalidBadProtocolVersion(t *testing.T) {
 	_, err = LoadBackendManifests([]string{d})
 	assert.Error(t, err)
 }
+
+func TestLoadBackendManifestsOptionsSchemaInline(t *testing.T) {
+	d := t.TempDir()
+	p := path.Join(d, "backend.yaml")
+	err := os.WriteFile(p,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			name: backend
+			bin: /usr/bin/backend
+			protocol-version: 1
+			options-schema:
+				type: object
+				required: [repo]
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+	backendManifests, err := LoadBackendManifests([]string{d})
+	if assert.NoError(t, err) && assert.Len(t, backendManifests, 1) {
+		assert.Equal(t, map[string]any{
+			"type":     "object",
+			"required": []any{"repo"},
+		}, backendManifests[0].OptionsSchema)
+	}
+}
+
+func TestLoadBackendManifestsOptionsSchemaFile(t *testing.T) {
+	d := t.TempDir()
+	err := os.WriteFile(path.Join(d, "options.schema.json"),
+		[]byte(`{"type": "object", "required": ["repo"]}`),
+		0o644,
+	)
+	require.NoError(t, err)
+	err = os.WriteFile(path.Join(d, "backend.yaml"),
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			name: backend
+			bin: /usr/bin/backend
+			protocol-version: 1
+			options-schema: options.schema.json
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+	backendManifests, err := LoadBackendManifests([]string{d})
+	if assert.NoError(t, err) && assert.Len(t, backendManifests, 1) {
+		assert.Equal(t, map[string]any{
+			"type":     "object",
+			"required": []any{"repo"},
+		}, backendManifests[0].OptionsSchema)
+	}
+}
+
+func TestLoadBackendManifestsOptionsSchemaFileNotFound(t *testing.T) {
+	d := t.TempDir()
+	err := os.WriteFile(path.Join(d, "backend.yaml"),
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			name: backend
+			bin: /usr/bin/backend
+			protocol-version: 1
+			options-schema: does-not-exist.json
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+	_, err = LoadBackendManifests([]string{d})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadBackendManifestsOptionsSchemaInvalid(t *testing.T) {
+	d := t.TempDir()
+	err := os.WriteFile(path.Join(d, "backend.yaml"),
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			name: backend
+			bin: /usr/bin/backend
+			protocol-version: 1
+			options-schema:
+				type: not-a-type
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+	_, err = LoadBackendManifests([]string{d})
+	assert.ErrorContains(t, err, "has an invalid options schema")
+}
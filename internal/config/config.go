@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+
+	"github.com/go-viper/mapstructure/v2"
 )
 
 // Config describes the entire configuration of `standard-backups` across all config files.
@@ -63,3 +65,16 @@ func (c *Config) GetRecipeManifest(name string) (*RecipeManifestV1, error) {
 	}
 	return nil, fmt.Errorf("could not find recipe named %s", name)
 }
+
+// decode is like mapstructure.Decode except that it also converts duration
+// strings (ex: 1m30s) to time.Duration.
+func decode(input any, output any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     output,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
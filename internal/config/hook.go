@@ -1,8 +1,17 @@
 package config
 
-import "github.com/santhosh-tekuri/jsonschema/v6"
+import (
+	"errors"
+	"fmt"
+	"time"
 
-const hookSchemaUrl = "standard-backups://hook.schema.json"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	hookSchemaUrl   = "standard-backups://hook.schema.json"
+	durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+)
 
 var (
 	hookSchemaDoc = map[string]any{
@@ -11,11 +20,28 @@ var (
 		"properties": map[string]any{
 			"shell":   map[string]any{"enum": []any{"bash", "sh"}},
 			"command": map[string]any{"type": "string"},
+			"timeout": durationSchema,
 		},
 	}
 	hookSchemaRef = map[string]any{"$ref": hookSchemaUrl}
+	// Durations are strings parsed with time.ParseDuration (ex: 1m30s)
+	durationSchema = map[string]any{"type": "string", "pattern": durationPattern}
 )
 
+// ErrUnsupportedShell is returned for hooks with a shell that can't run them.
+var ErrUnsupportedShell = errors.New("unsupported shell")
+
+// ShellCommand returns the program and the arguments that run the hook's
+// command through its shell.
+func (h *HookV1) ShellCommand() (string, []string, error) {
+	switch h.Shell {
+	case "sh", "bash":
+		return h.Shell, []string{"-c", h.Command}, nil
+	default:
+		return "", nil, fmt.Errorf("%w %s", ErrUnsupportedShell, h.Shell)
+	}
+}
+
 func addHookSchema(compiler *jsonschema.Compiler) error {
 	return compiler.AddResource(hookSchemaUrl, hookSchemaDoc)
 }
@@ -23,4 +49,6 @@ func addHookSchema(compiler *jsonschema.Compiler) error {
 type HookV1 struct {
 	Shell   string `mapstructure:"shell"`
 	Command string `mapstructure:"command"`
+	// The hook is stopped when it runs for longer than this. 0 means no limit.
+	Timeout time.Duration `mapstructure:"timeout"`
 }
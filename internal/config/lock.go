@@ -0,0 +1,47 @@
+package config
+
+import (
+	"time"
+)
+
+const (
+	// LockPolicyFail makes the backup fail when the job is already running
+	LockPolicyFail = "fail"
+	// LockPolicyWait makes the backup wait for the running job to complete
+	LockPolicyWait = "wait"
+	// LockPolicySkip makes the backup do nothing when the job is already running
+	LockPolicySkip = "skip"
+)
+
+var lockSchema = map[string]any{
+	"type":                 "object",
+	"additionalProperties": false,
+	"properties": map[string]any{
+		"policy": map[string]any{
+			"enum": []any{LockPolicyFail, LockPolicyWait, LockPolicySkip},
+		},
+		"timeout": durationSchema,
+	},
+}
+
+// LockV1 controls what happens when a job is started while it's already
+// running.
+type LockV1 struct {
+	Policy string
+	// Limit on how long to wait for the lock with the wait policy. 0 waits
+	// forever.
+	Timeout time.Duration
+}
+
+// GetLock returns the lock settings for the given job. The policy defaults to
+// fail.
+func GetLock(job JobConfigV1) LockV1 {
+	res := LockV1{Policy: LockPolicyFail}
+	if job.Lock != nil {
+		res = *job.Lock
+		if res.Policy == "" {
+			res.Policy = LockPolicyFail
+		}
+	}
+	return res
+}
@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/go-viper/mapstructure/v2"
 	"github.com/goccy/go-yaml"
 	"github.com/santhosh-tekuri/jsonschema/v6"
 )
@@ -21,25 +24,75 @@ type (
 		Options        map[string]any
 		DefaultVariant string `mapstructure:"default-variant"`
 		Variants       map[string]map[string]any
+		Retry          *RetryV1
+		// Limit on how long a single backup attempt can take
+		Timeout time.Duration
 	}
 	JobConfigV1 struct {
-		Recipe    string
-		BackupTo  []string `mapstructure:"backup-to"`
-		OnSuccess *HookV1  `mapstructure:"on-success"`
-		OnFailure *HookV1  `mapstructure:"on-failure"`
+		Recipe      string
+		BackupTo    []string `mapstructure:"backup-to"`
+		Tags        []string
+		OnSuccess   *HookV1 `mapstructure:"on-success"`
+		OnFailure   *HookV1 `mapstructure:"on-failure"`
+		Parallelism int
+		Retry       *RetryV1
+		// Limit on how long backing up to all destinations can take. Hooks have
+		// their own timeouts.
+		Timeout time.Duration
+		Lock    *LockV1
+		// How old the last successful backup can be before the job is reported
+		// as stale. 0 means that there's no limit.
+		MaxAge time.Duration `mapstructure:"max-age"`
+		// Cron expression telling the daemon when to run the job. Jobs without
+		// a schedule only run when started by hand.
+		Schedule string
+		// How often the job should succeed. Used by backup --if-due.
+		Interval time.Duration
 	}
 	SecretConfigV1 struct {
 		FromFile string `mapstructure:"from-file"`
 		Literal  string
+		// Name of an environment variable holding the secret
+		FromEnv string `mapstructure:"from-env"`
+		// Command that prints the secret on stdout (ex: pass show my-secret)
+		FromCommand *HookV1 `mapstructure:"from-command"`
+		// Name of a systemd credential (see LoadCredential= in systemd.exec(5))
+		FromSystemdCredential string                 `mapstructure:"from-systemd-credential"`
+		FromEncryptedFile     *SecretEncryptedFileV1 `mapstructure:"from-encrypted-file"`
+		// Remove whitespace around the value. Defaults to true.
+		Trim *bool
+		// How the value is encoded (raw or base64). Defaults to raw.
+		Encoding string
+		// Reject values with fewer characters than this
+		MinLength int `mapstructure:"min-length"`
+	}
+	// SecretEncryptedFileV1 is a file encrypted with age. Relative paths are
+	// relative to the main config.
+	SecretEncryptedFileV1 struct {
+		Path string
+		// Key of the secret in the decrypted file. The decrypted file must be a
+		// YAML or JSON object. When empty, the whole decrypted file is the
+		// secret.
+		Key string
+		// age identity file used to decrypt the file
+		Identity string
 	}
 	// MainConfig is the configuration file that system administrators are expected
 	// to write. In other words, it's `config.yaml`.
 	MainConfig struct {
-		path         string
-		Version      int
-		Destinations map[string]DestinationConfigV1
-		Jobs         map[string]JobConfigV1
-		Secrets      map[string]SecretConfigV1
+		path        string
+		Version     int
+		Parallelism int
+		RuntimeDir  string `mapstructure:"runtime-dir"`
+		StateDir    string `mapstructure:"state-dir"`
+		// What to do when from-file secrets can be read or replaced by other
+		// users (ignore, warn or fail). Defaults to warn.
+		SecretPermissions string `mapstructure:"secret-permissions"`
+		Metrics           *MetricsV1
+		Notify            *NotifyV1
+		Destinations      map[string]DestinationConfigV1
+		Jobs              map[string]JobConfigV1
+		Secrets           map[string]SecretConfigV1
 	}
 )
 
@@ -67,6 +120,17 @@ func makeMainConfigSchema(
 		},
 		"properties": map[string]any{
 			"version": map[string]any{"const": 1},
+			"parallelism": map[string]any{
+				"type":    "integer",
+				"minimum": 1,
+			},
+			"runtime-dir": map[string]any{"type": "string", "minLength": 1},
+			"state-dir":   map[string]any{"type": "string", "minLength": 1},
+			"secret-permissions": map[string]any{
+				"enum": []any{SecretPermissionsIgnore, SecretPermissionsWarn, SecretPermissionsFail},
+			},
+			"metrics": metricsSchema,
+			"notify":  notifySchema,
 			"destinations": map[string]any{
 				"type":                 "object",
 				"additionalProperties": false,
@@ -76,7 +140,8 @@ func makeMainConfigSchema(
 						"required": []any{"backend"},
 						"properties": map[string]any{
 							"backend": map[string]any{"enum": backendNames},
-							// TODO: allow backend to setup schema here
+							// Backend specific schemas are checked in Config.Validate once secrets are
+							// filled in
 							"options": map[string]any{
 								"type": "object",
 							},
@@ -93,6 +158,8 @@ func makeMainConfigSchema(
 									},
 								},
 							},
+							"retry":   retrySchemaRef,
+							"timeout": durationSchema,
 						},
 					},
 				},
@@ -112,8 +179,28 @@ func makeMainConfigSchema(
 									"type": "string",
 								},
 							},
+							"tags": map[string]any{
+								"type": "array",
+								"items": map[string]any{
+									"type":    "string",
+									"pattern": dynamicPropPattern,
+								},
+							},
 							"on-success": hookSchemaRef,
 							"on-failure": hookSchemaRef,
+							"parallelism": map[string]any{
+								"type":    "integer",
+								"minimum": 1,
+							},
+							"retry":   retrySchemaRef,
+							"timeout": durationSchema,
+							"lock":    lockSchema,
+							"max-age": durationSchema,
+							"schedule": map[string]any{
+								"type":      "string",
+								"minLength": 1,
+							},
+							"interval": durationSchema,
 						},
 					},
 				},
@@ -122,16 +209,7 @@ func makeMainConfigSchema(
 				"type":                 "object",
 				"additionalProperties": false,
 				"patternProperties": map[string]any{
-					dynamicPropPattern: map[string]any{
-						"type":                 "object",
-						"minProperties":        1,
-						"maxProperties":        1,
-						"additionalProperties": false,
-						"properties": map[string]any{
-							"from-file": map[string]any{"type": "string"},
-							"literal":   map[string]any{"type": "string"},
-						},
-					},
+					dynamicPropPattern: secretSchema,
 				},
 			},
 		},
@@ -143,6 +221,10 @@ func makeMainConfigSchema(
 	if err != nil {
 		return nil, err
 	}
+	err = addRetrySchema(compiler)
+	if err != nil {
+		return nil, err
+	}
 	schema, err := compiler.Compile(mainConfigV1SchemaUrl)
 	if err != nil {
 		return nil, err
@@ -177,15 +259,110 @@ func LoadMainConfig(
 	}
 
 	var res MainConfig
-	err = mapstructure.Decode(rawConfig, &res)
+	err = decode(rawConfig, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode main config %s: %w", path, err)
 	}
 	res.path = path
+	res.resolveSecretPaths()
 
 	return &res, nil
 }
 
+// Path returns the path of the file the main config was loaded from.
+func (mc *MainConfig) Path() string {
+	return mc.path
+}
+
+// GetJobParallelism returns the maximum number of destinations that the given
+// job backs up to at the same time. Jobs default to the top level setting which
+// defaults to one destination at a time.
+func (mc *MainConfig) GetJobParallelism(job JobConfigV1) int {
+	if job.Parallelism > 0 {
+		return job.Parallelism
+	}
+	if mc.Parallelism > 0 {
+		return mc.Parallelism
+	}
+	return 1
+}
+
+// SelectJobs returns the names of the jobs to run. It includes every job when
+// all is set. Otherwise, it includes the jobs named in names followed by the
+// jobs that have at least one of the given tags. Jobs are never repeated.
+func (mc *MainConfig) SelectJobs(names []string, all bool, tags []string) ([]string, error) {
+	jobNames := make([]string, 0, len(mc.Jobs))
+	for name := range mc.Jobs {
+		jobNames = append(jobNames, name)
+	}
+	sort.Strings(jobNames)
+	if all {
+		return jobNames, nil
+	}
+
+	res := []string{}
+	for _, name := range names {
+		if _, ok := mc.Jobs[name]; !ok {
+			return nil, fmt.Errorf("could not find a job named %s", name)
+		}
+		if !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+	for _, tag := range tags {
+		found := false
+		for _, name := range jobNames {
+			if !slices.Contains(mc.Jobs[name].Tags, tag) {
+				continue
+			}
+			found = true
+			if !slices.Contains(res, name) {
+				res = append(res, name)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("could not find any job tagged with %s", tag)
+		}
+	}
+	return res, nil
+}
+
+// GetRuntimeDir returns the directory where runtime files like job locks go.
+// Defaults to $XDG_RUNTIME_DIR/standard-backups or /run/standard-backups when
+// XDG_RUNTIME_DIR is not set.
+func (mc *MainConfig) GetRuntimeDir() string {
+	if mc.RuntimeDir != "" {
+		return mc.RuntimeDir
+	}
+	if xdgRuntimeDir := os.Getenv("XDG_RUNTIME_DIR"); xdgRuntimeDir != "" {
+		return path.Join(xdgRuntimeDir, "standard-backups")
+	}
+	return "/run/standard-backups"
+}
+
+// GetStateDir returns the directory where standard-backups keeps data that
+// needs to persist between runs (ex: the run history). Defaults to
+// $XDG_STATE_HOME/standard-backups or /var/lib/standard-backups when
+// XDG_STATE_HOME is not set.
+func (mc *MainConfig) GetStateDir() string {
+	if mc.StateDir != "" {
+		return mc.StateDir
+	}
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		return path.Join(xdgStateHome, "standard-backups")
+	}
+	return "/var/lib/standard-backups"
+}
+
+// GetSecretPermissions returns what to do when the files of from-file secrets
+// have unsafe permissions. Defaults to warn.
+func (mc *MainConfig) GetSecretPermissions() string {
+	if mc.SecretPermissions != "" {
+		return mc.SecretPermissions
+	}
+	return SecretPermissionsWarn
+}
+
 func (mc *MainConfig) applyTemplate(template *configTemplate) error {
 	for key, dest := range mc.Destinations {
 		p := fmt.Sprintf("destinations.%s.options", key)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/dotboris/standard-backups/internal/testutils"
 	"github.com/santhosh-tekuri/jsonschema/v6"
@@ -285,7 +286,7 @@ func TestLoadMainConfigSecretDefinitionOneProperty(t *testing.T) {
 		assert.Equal(t,
 			testutils.Dedent(`
 				jsonschema validation failed with 'standard-backups://main-config-v1.schema.json#'
-				- at '/secrets/mySecret': maxProperties: got 2, want 1
+				- at '/secrets/mySecret': 'oneOf' failed, subschemas 0, 1 matched
 			`),
 			validationErr.Error(),
 		)
@@ -315,7 +316,13 @@ func TestLoadMainConfigSecretDefinitionNoProperties(t *testing.T) {
 		assert.Equal(t,
 			testutils.Dedent(`
 				jsonschema validation failed with 'standard-backups://main-config-v1.schema.json#'
-				- at '/secrets/mySecret': minProperties: got 0, want 1
+				- at '/secrets/mySecret': 'oneOf' failed, none matched
+				  - at '/secrets/mySecret': missing property 'from-file'
+				  - at '/secrets/mySecret': missing property 'literal'
+				  - at '/secrets/mySecret': missing property 'from-env'
+				  - at '/secrets/mySecret': missing property 'from-command'
+				  - at '/secrets/mySecret': missing property 'from-systemd-credential'
+				  - at '/secrets/mySecret': missing property 'from-encrypted-file'
 			`),
 			validationErr.Error(),
 		)
@@ -347,6 +354,13 @@ func TestLoadMainConfigSecretDefinitionBadProperties(t *testing.T) {
 			testutils.Dedent(`
 				jsonschema validation failed with 'standard-backups://main-config-v1.schema.json#'
 				- at '/secrets/mySecret': additional properties 'bogus' not allowed
+				- at '/secrets/mySecret': 'oneOf' failed, none matched
+				  - at '/secrets/mySecret': missing property 'from-file'
+				  - at '/secrets/mySecret': missing property 'literal'
+				  - at '/secrets/mySecret': missing property 'from-env'
+				  - at '/secrets/mySecret': missing property 'from-command'
+				  - at '/secrets/mySecret': missing property 'from-systemd-credential'
+				  - at '/secrets/mySecret': missing property 'from-encrypted-file'
 			`),
 			validationErr.Error(),
 		)
@@ -452,6 +466,366 @@ func TestLoadMainConfigJobBadHooks(t *testing.T) {
 	}
 }
 
+func TestLoadMainConfigBadParallelism(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name: "global",
+			config: `
+				version: 1
+				parallelism: 0
+			`,
+			expected: "- at '/parallelism': minimum: got 0, want 1",
+		},
+		{
+			name: "job",
+			config: `
+				version: 1
+				jobs:
+					my-job:
+						recipe: bogus
+						backup-to: []
+						parallelism: 1.5
+			`,
+			expected: "- at '/jobs/my-job/parallelism': got number, want integer",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := t.TempDir()
+			configPath := path.Join(d, "config.yaml")
+			err := os.WriteFile(configPath, []byte(testutils.DedentYaml(c.config)), 0o644)
+			require.NoError(t, err)
+
+			_, err = LoadMainConfig(
+				configPath,
+				[]BackendManifestV1{},
+				[]RecipeManifestV1{
+					{Version: 1, Name: "bogus"},
+				},
+			)
+			var validationErr *jsonschema.ValidationError
+			if assert.Error(t, err) && assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(
+					t,
+					"jsonschema validation failed with 'standard-backups://main-config-v1.schema.json#'\n"+
+						c.expected,
+					validationErr.Error(),
+				)
+			}
+		})
+	}
+}
+
+func TestLoadMainConfigRetry(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			destinations:
+				my-dest:
+					backend: bogus
+					retry:
+						attempts: 2
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: [my-dest]
+					retry:
+						attempts: 4
+						initial-delay: 1m30s
+						max-delay: 1h
+						multiplier: 1.5
+						jitter: 0.2
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{{Version: 1, Name: "bogus"}},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &RetryV1{Attempts: 2}, mainConfig.Destinations["my-dest"].Retry)
+		assert.Equal(t, &RetryV1{
+			Attempts:     4,
+			InitialDelay: 90 * time.Second,
+			MaxDelay:     time.Hour,
+			Multiplier:   1.5,
+			Jitter:       0.2,
+		}, mainConfig.Jobs["my-job"].Retry)
+	}
+}
+
+func TestLoadMainConfigBadRetry(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: []
+					retry:
+						initial-delay: 10 parsecs
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	_, err = LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	var validationErr *jsonschema.ValidationError
+	if assert.Error(t, err) && assert.ErrorAs(t, err, &validationErr) {
+		assert.Contains(t, validationErr.Error(), "- at '/jobs/my-job/retry/initial-delay'")
+	}
+}
+
+func TestLoadMainConfigLock(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			runtime-dir: /some/where
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: []
+					lock:
+						policy: wait
+						timeout: 1h
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/some/where", mainConfig.RuntimeDir)
+		assert.Equal(t, &LockV1{Policy: LockPolicyWait, Timeout: time.Hour},
+			mainConfig.Jobs["my-job"].Lock)
+	}
+}
+
+func TestLoadMainConfigScheduleInterval(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: []
+					schedule: "0 3 * * *"
+					interval: 24h
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0 3 * * *", mainConfig.Jobs["my-job"].Schedule)
+		assert.Equal(t, 24*time.Hour, mainConfig.Jobs["my-job"].Interval)
+	}
+}
+
+func TestLoadMainConfigMetrics(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			metrics:
+				textfile-dir: /var/lib/node_exporter/textfile
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &MetricsV1{TextfileDir: "/var/lib/node_exporter/textfile"},
+			mainConfig.Metrics)
+	}
+}
+
+func TestLoadMainConfigMaxAge(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: []
+					max-age: 26h
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 26*time.Hour, mainConfig.Jobs["my-job"].MaxAge)
+	}
+}
+
+func TestLoadMainConfigBadLockPolicy(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			jobs:
+				my-job:
+					recipe: bogus
+					backup-to: []
+					lock:
+						policy: steal
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	_, err = LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{},
+		[]RecipeManifestV1{{Version: 1, Name: "bogus"}},
+	)
+	var validationErr *jsonschema.ValidationError
+	if assert.Error(t, err) && assert.ErrorAs(t, err, &validationErr) {
+		assert.Contains(t, validationErr.Error(), "- at '/jobs/my-job/lock/policy'")
+	}
+}
+
+func TestGetLock(t *testing.T) {
+	assert.Equal(t, LockV1{Policy: LockPolicyFail}, GetLock(JobConfigV1{}))
+	assert.Equal(t, LockV1{Policy: LockPolicyFail, Timeout: time.Minute},
+		GetLock(JobConfigV1{Lock: &LockV1{Timeout: time.Minute}}))
+	assert.Equal(t, LockV1{Policy: LockPolicySkip},
+		GetLock(JobConfigV1{Lock: &LockV1{Policy: LockPolicySkip}}))
+}
+
+func TestSelectJobs(t *testing.T) {
+	mc := MainConfig{
+		Jobs: map[string]JobConfigV1{
+			"a": {Tags: []string{"nightly"}},
+			"b": {Tags: []string{"nightly", "db"}},
+			"c": {Tags: []string{"weekly"}},
+			"d": {},
+		},
+	}
+	cases := []struct {
+		name     string
+		names    []string
+		all      bool
+		tags     []string
+		expected []string
+	}{
+		{name: "nothing", expected: []string{}},
+		{name: "all", all: true, expected: []string{"a", "b", "c", "d"}},
+		{name: "names", names: []string{"d", "a"}, expected: []string{"d", "a"}},
+		{name: "repeated names", names: []string{"a", "a"}, expected: []string{"a"}},
+		{name: "tag", tags: []string{"nightly"}, expected: []string{"a", "b"}},
+		{name: "tags", tags: []string{"db", "weekly"}, expected: []string{"b", "c"}},
+		{
+			name:     "names and tags",
+			names:    []string{"b"},
+			tags:     []string{"nightly"},
+			expected: []string{"b", "a"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := mc.SelectJobs(c.names, c.all, c.tags)
+			if assert.NoError(t, err) {
+				assert.Equal(t, c.expected, res)
+			}
+		})
+	}
+}
+
+func TestSelectJobsErrors(t *testing.T) {
+	mc := MainConfig{
+		Jobs: map[string]JobConfigV1{
+			"a": {Tags: []string{"nightly"}},
+		},
+	}
+	_, err := mc.SelectJobs([]string{"nope"}, false, nil)
+	assert.EqualError(t, err, "could not find a job named nope")
+	_, err = mc.SelectJobs(nil, false, []string{"nope"})
+	assert.EqualError(t, err, "could not find any job tagged with nope")
+}
+
+func TestGetRuntimeDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	assert.Equal(t, "/run/standard-backups", (&MainConfig{}).GetRuntimeDir())
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	assert.Equal(t, "/run/user/1000/standard-backups", (&MainConfig{}).GetRuntimeDir())
+	assert.Equal(t, "/some/where", (&MainConfig{RuntimeDir: "/some/where"}).GetRuntimeDir())
+}
+
+func TestGetStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "")
+	assert.Equal(t, "/var/lib/standard-backups", (&MainConfig{}).GetStateDir())
+	t.Setenv("XDG_STATE_HOME", "/home/me/.local/state")
+	assert.Equal(t, "/home/me/.local/state/standard-backups", (&MainConfig{}).GetStateDir())
+	assert.Equal(t, "/some/where", (&MainConfig{StateDir: "/some/where"}).GetStateDir())
+}
+
+func TestGetJobParallelism(t *testing.T) {
+	cases := []struct {
+		name     string
+		global   int
+		job      int
+		expected int
+	}{
+		{name: "default", expected: 1},
+		{name: "global", global: 3, expected: 3},
+		{name: "job", job: 2, expected: 2},
+		{name: "job overrides global", global: 3, job: 2, expected: 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mc := MainConfig{Parallelism: c.global}
+			assert.Equal(t, c.expected, mc.GetJobParallelism(JobConfigV1{Parallelism: c.job}))
+		})
+	}
+}
+
 func TestGetDestinationDirect(t *testing.T) {
 	c := MainConfig{
 		Destinations: map[string]DestinationConfigV1{
@@ -564,3 +938,325 @@ func TestGetDestinationVariantComplexMerge(t *testing.T) {
 		},
 	}, *dest)
 }
+
+var testOptionsSchemaBackend = BackendManifestV1{
+	Version:         1,
+	Name:            "strict",
+	ProtocolVersion: 1,
+	Bin:             "strict",
+	OptionsSchema: map[string]any{
+		"type":                 "object",
+		"required":             []any{"repo"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"repo": map[string]any{"type": "string"},
+			"forget": map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"keep-daily": map[string]any{"type": "integer"},
+				},
+			},
+		},
+	},
+}
+
+func TestValidateDestinationOptionsTemplated(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			destinations:
+				good:
+					backend: strict
+					options:
+						repo: '{{ .Secrets.repo }}'
+						forget: { keep-daily: 7 }
+				bad:
+					backend: strict
+					options:
+						forget: { keep-dayly: 7 }
+				templated:
+					backend: strict
+					options:
+						repo: /path/to/repo
+						forget: { keep-daily: '{{ .Secrets.keep }}' }
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+	// Options are only checked once secrets are filled in
+	mainConfig, err := LoadMainConfig(
+		configPath,
+		[]BackendManifestV1{testOptionsSchemaBackend},
+		[]RecipeManifestV1{},
+	)
+	require.NoError(t, err)
+	err = mainConfig.applyTemplate(&configTemplate{
+		Secrets: map[string]string{"repo": "/path/to/repo", "keep": "7"},
+	})
+	require.NoError(t, err)
+
+	res := mainConfig.validateDestinationOptions([]BackendManifestV1{testOptionsSchemaBackend})
+	if assert.Len(t, res, 3) {
+		for _, err := range res {
+			assert.Equal(t, configPath, err.File)
+		}
+		assert.Equal(t, "/destinations/bad/options", res[0].FieldPath)
+		assert.EqualError(t, res[0].Err, "missing property 'repo'")
+		assert.Equal(t, "/destinations/bad/options/forget", res[1].FieldPath)
+		assert.EqualError(t, res[1].Err, "additional properties 'keep-dayly' not allowed")
+		// Templates always produce strings
+		assert.Equal(t, "/destinations/templated/options/forget/keep-daily", res[2].FieldPath)
+		assert.EqualError(t, res[2].Err, "got string, want integer")
+	}
+}
+
+func TestValidateDestinationOptionsVariants(t *testing.T) {
+	c := MainConfig{
+		path: "bogus/config.yaml",
+		Destinations: map[string]DestinationConfigV1{
+			"dest": {
+				Backend: "strict",
+				Options: map[string]any{
+					"forget": map[string]any{"keep-daily": "nope"},
+				},
+				Variants: map[string]map[string]any{
+					"good": {
+						"repo":   "/path/to/repo",
+						"forget": map[string]any{"keep-daily": 7},
+					},
+					"bad": {
+						"repo": 42,
+					},
+				},
+			},
+		},
+	}
+
+	res := c.validateDestinationOptions([]BackendManifestV1{testOptionsSchemaBackend})
+	require.Len(t, res, 2)
+	assert.Equal(t, "bogus/config.yaml", res[0].File)
+	assert.Equal(t, "/destinations/dest/options/forget/keep-daily", res[0].FieldPath)
+	assert.EqualError(t, res[0].Err, "with variant bad: got string, want integer")
+	assert.Equal(t, "bogus/config.yaml", res[1].File)
+	assert.Equal(t, "/destinations/dest/variants/bad/repo", res[1].FieldPath)
+	assert.EqualError(t, res[1].Err, "with variant bad: got number, want string")
+}
+
+func TestValidateDestinationOptionsNoSchema(t *testing.T) {
+	c := MainConfig{
+		path: "bogus/config.yaml",
+		Destinations: map[string]DestinationConfigV1{
+			"dest": {
+				Backend: "lax",
+				Options: map[string]any{"anything": "goes"},
+			},
+		},
+	}
+
+	res := c.validateDestinationOptions([]BackendManifestV1{
+		{Version: 1, Name: "lax", ProtocolVersion: 1, Bin: "lax"},
+	})
+	assert.Empty(t, res)
+}
+
+func TestLoadMainConfigNotify(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			notify:
+				webhooks:
+					slack:
+						url: https://hooks.slack.com/services/{{ .Secrets.slackToken }}
+						headers:
+							X-Custom: value
+						body: '{"text": {{ json .Job }}}'
+						on: [failure]
+						jobs: [my-job]
+						timeout: 10s
+						retry:
+							attempts: 3
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	if assert.NoError(t, err) && assert.NotNil(t, mainConfig.Notify) {
+		wh := mainConfig.Notify.Webhooks["slack"]
+		assert.Equal(t, "https://hooks.slack.com/services/{{ .Secrets.slackToken }}", wh.URL)
+		assert.Equal(t, "POST", wh.GetMethod())
+		assert.Equal(t, `{"text": {{ json .Job }}}`, wh.Body)
+		assert.Equal(t, []string{NotifyOnFailure}, wh.On)
+		assert.Equal(t, []string{"my-job"}, wh.Jobs)
+		assert.Equal(t, 10*time.Second, wh.GetTimeout())
+		assert.Equal(t, 3, wh.GetRetry().Attempts)
+		assert.Len(t, wh.Headers, 1)
+	}
+}
+
+func TestLoadMainConfigBadNotifyOn(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			notify:
+				webhooks:
+					hook:
+						url: http://localhost
+						on: [sometimes]
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	_, err = LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	assert.Error(t, err)
+}
+
+func TestLoadMainConfigNotifyEmail(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			notify:
+				email:
+					host: smtp.example.com
+					starttls: false
+					username: backups
+					password: '{{ .Secrets.smtpPassword }}'
+					from: Backups <backups@example.com>
+					to: [admin@example.com]
+					on: [failure]
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	if assert.NoError(t, err) && assert.NotNil(t, mainConfig.Notify) {
+		email := mainConfig.Notify.Email
+		if assert.NotNil(t, email) {
+			assert.Equal(t, "smtp.example.com", email.Host)
+			assert.Equal(t, 587, email.GetPort())
+			assert.False(t, email.UseStartTLS())
+			assert.Equal(t, "backups", email.Username)
+			assert.Equal(t, "{{ .Secrets.smtpPassword }}", email.Password)
+			assert.Equal(t, "Backups <backups@example.com>", email.From)
+			assert.Equal(t, []string{"admin@example.com"}, email.To)
+			assert.True(t, email.Matches("any-job", false))
+			assert.False(t, email.Matches("any-job", true))
+		}
+	}
+}
+
+func TestLoadMainConfigNotifyEmailMissingTo(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			notify:
+				email:
+					host: smtp.example.com
+					from: backups@example.com
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	_, err = LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	assert.Error(t, err)
+}
+
+func TestLoadMainConfigBadSystemdCredential(t *testing.T) {
+	for _, name := range []string{"../etc/shadow", "..", ""} {
+		t.Run(name, func(t *testing.T) {
+			d := t.TempDir()
+			configPath := path.Join(d, "config.yaml")
+			err := os.WriteFile(
+				configPath,
+				[]byte(testutils.DedentYaml(fmt.Sprintf(`
+					version: 1
+					secrets:
+						password:
+							from-systemd-credential: %q
+				`, name))),
+				0o644,
+			)
+			require.NoError(t, err)
+
+			_, err = LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadMainConfigEncryptedFileRelativePaths(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			secrets:
+				password:
+					from-encrypted-file:
+						path: secrets.age
+						key: password
+						identity: /etc/standard-backups/key.txt
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &SecretEncryptedFileV1{
+			Path:     path.Join(d, "secrets.age"),
+			Key:      "password",
+			Identity: "/etc/standard-backups/key.txt",
+		}, mainConfig.Secrets["password"].FromEncryptedFile)
+	}
+}
+
+func TestLoadMainConfigSecretOptions(t *testing.T) {
+	d := t.TempDir()
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			secrets:
+				mySecret:
+					from-file: /path/to/secret.txt
+					trim: false
+					encoding: base64
+					min-length: 16
+		`)),
+		0o644,
+	)
+	require.NoError(t, err)
+
+	mainConfig, err := LoadMainConfig(configPath, []BackendManifestV1{}, []RecipeManifestV1{})
+	if assert.NoError(t, err) {
+		noTrim := false
+		assert.Equal(t, SecretConfigV1{
+			FromFile:  "/path/to/secret.txt",
+			Trim:      &noTrim,
+			Encoding:  SecretEncodingBase64,
+			MinLength: 16,
+		}, mainConfig.Secrets["mySecret"])
+	}
+}
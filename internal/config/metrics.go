@@ -0,0 +1,17 @@
+package config
+
+var metricsSchema = map[string]any{
+	"type":                 "object",
+	"additionalProperties": false,
+	"properties": map[string]any{
+		"textfile-dir": map[string]any{"type": "string", "minLength": 1},
+	},
+}
+
+// MetricsV1 controls how metrics about backups are exported.
+type MetricsV1 struct {
+	// Directory read by node_exporter's textfile collector. Every job writes
+	// its metrics to its own .prom file in there. Nothing is written when
+	// empty.
+	TextfileDir string `mapstructure:"textfile-dir"`
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// NotifyOnSuccess sends notifications when jobs succeed
+	NotifyOnSuccess = "success"
+	// NotifyOnFailure sends notifications when jobs fail
+	NotifyOnFailure = "failure"
+)
+
+const (
+	defaultWebhookTimeout = 30 * time.Second
+	defaultEmailTimeout   = 30 * time.Second
+	defaultEmailPort      = 587
+	defaultEmailSubject   = "[standard-backups] {{ .Job }}: {{ .Status }}"
+)
+
+var notifyOnSchema = map[string]any{
+	"type":  "array",
+	"items": map[string]any{"enum": []any{NotifyOnSuccess, NotifyOnFailure}},
+}
+
+var notifyJobsSchema = map[string]any{
+	"type":  "array",
+	"items": map[string]any{"type": "string"},
+}
+
+var notifySchema = map[string]any{
+	"type":                 "object",
+	"additionalProperties": false,
+	"properties": map[string]any{
+		"webhooks": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"patternProperties": map[string]any{
+				dynamicPropPattern: map[string]any{
+					"type":                 "object",
+					"additionalProperties": false,
+					"required":             []any{"url"},
+					"properties": map[string]any{
+						"url":    map[string]any{"type": "string", "minLength": 1},
+						"method": map[string]any{"type": "string", "minLength": 1},
+						"headers": map[string]any{
+							"type":                 "object",
+							"additionalProperties": map[string]any{"type": "string"},
+						},
+						"body":    map[string]any{"type": "string"},
+						"on":      notifyOnSchema,
+						"jobs":    notifyJobsSchema,
+						"timeout": durationSchema,
+						"retry":   retrySchemaRef,
+					},
+				},
+			},
+		},
+		"email": map[string]any{
+			"type":                 "object",
+			"additionalProperties": false,
+			"required":             []any{"host", "from", "to"},
+			"properties": map[string]any{
+				"host":     map[string]any{"type": "string", "minLength": 1},
+				"port":     map[string]any{"type": "integer", "minimum": 1, "maximum": 65535},
+				"starttls": map[string]any{"type": "boolean"},
+				"username": map[string]any{"type": "string"},
+				"password": map[string]any{"type": "string"},
+				"from":     map[string]any{"type": "string", "minLength": 1},
+				"to": map[string]any{
+					"type":     "array",
+					"minItems": 1,
+					"items":    map[string]any{"type": "string", "minLength": 1},
+				},
+				"subject": map[string]any{"type": "string", "minLength": 1},
+				"on":      notifyOnSchema,
+				"jobs":    notifyJobsSchema,
+				"timeout": durationSchema,
+				"retry":   retrySchemaRef,
+			},
+		},
+	},
+}
+
+// NotifyV1 configures notifications sent at the end of every job.
+type NotifyV1 struct {
+	Webhooks map[string]WebhookV1
+	Email    *EmailV1
+}
+
+// WebhookV1 is an HTTP request sent at the end of jobs. The URL, headers and
+// body are templates that have access to the outcome of the job and to
+// secrets.
+type WebhookV1 struct {
+	URL string
+	// Defaults to POST
+	Method  string
+	Headers map[string]string
+	// Defaults to a JSON document describing the outcome of the job
+	Body string
+	// When to send the webhook. Defaults to both success and failure.
+	On []string
+	// Jobs that send the webhook. Defaults to all jobs.
+	Jobs []string
+	// Limit on how long a single attempt can take. Defaults to 30s.
+	Timeout time.Duration
+	Retry   *RetryV1
+}
+
+// Matches returns true if the webhook should be sent at the end of the given
+// job.
+func (w WebhookV1) Matches(jobName string, success bool) bool {
+	return notifyMatches(w.On, w.Jobs, jobName, success)
+}
+
+// GetMethod returns the HTTP method of the webhook.
+func (w WebhookV1) GetMethod() string {
+	if w.Method == "" {
+		return "POST"
+	}
+	return w.Method
+}
+
+// GetTimeout returns how long a single attempt at sending the webhook can take.
+func (w WebhookV1) GetTimeout() time.Duration {
+	if w.Timeout == 0 {
+		return defaultWebhookTimeout
+	}
+	return w.Timeout
+}
+
+// GetRetry returns the retry policy of the webhook. Webhooks are only sent
+// once unless they configure retries.
+func (w WebhookV1) GetRetry() RetryV1 {
+	if w.Retry == nil {
+		return noRetry
+	}
+	return w.Retry.withDefaults()
+}
+
+// EmailV1 is an email summarizing a job sent through an SMTP server at the end
+// of jobs.
+type EmailV1 struct {
+	Host string
+	// Defaults to 587
+	Port int
+	// Upgrade the connection to TLS with STARTTLS. Defaults to true.
+	StartTLS *bool
+	Username string
+	// Template with access to secrets (ex: {{ .Secrets.smtpPassword }})
+	Password string
+	From     string
+	To       []string
+	// Template with access to the outcome of the job
+	Subject string
+	// When to send the email. Defaults to both success and failure.
+	On []string
+	// Jobs that send the email. Defaults to all jobs.
+	Jobs []string
+	// Limit on how long a single attempt can take. Defaults to 30s.
+	Timeout time.Duration
+	Retry   *RetryV1
+}
+
+// Matches returns true if the email should be sent at the end of the given
+// job.
+func (e EmailV1) Matches(jobName string, success bool) bool {
+	return notifyMatches(e.On, e.Jobs, jobName, success)
+}
+
+// GetPort returns the port of the SMTP server.
+func (e EmailV1) GetPort() int {
+	if e.Port == 0 {
+		return defaultEmailPort
+	}
+	return e.Port
+}
+
+// UseStartTLS returns true if the connection to the SMTP server should be
+// upgraded to TLS.
+func (e EmailV1) UseStartTLS() bool {
+	return e.StartTLS == nil || *e.StartTLS
+}
+
+// GetSubject returns the template of the subject of the email.
+func (e EmailV1) GetSubject() string {
+	if e.Subject == "" {
+		return defaultEmailSubject
+	}
+	return e.Subject
+}
+
+// GetTimeout returns how long a single attempt at sending the email can take.
+func (e EmailV1) GetTimeout() time.Duration {
+	if e.Timeout == 0 {
+		return defaultEmailTimeout
+	}
+	return e.Timeout
+}
+
+// GetRetry returns the retry policy of the email. Emails are only sent once
+// unless they configure retries.
+func (e EmailV1) GetRetry() RetryV1 {
+	if e.Retry == nil {
+		return noRetry
+	}
+	return e.Retry.withDefaults()
+}
+
+func notifyMatches(on []string, jobs []string, jobName string, success bool) bool {
+	if len(jobs) > 0 && !slices.Contains(jobs, jobName) {
+		return false
+	}
+	if len(on) == 0 {
+		return true
+	}
+	if success {
+		return slices.Contains(on, NotifyOnSuccess)
+	}
+	return slices.Contains(on, NotifyOnFailure)
+}
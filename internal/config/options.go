@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var optionsErrorPrinter = message.NewPrinter(language.English)
+
+func (b *BackendManifestV1) optionsSchemaUrl() string {
+	return fmt.Sprintf("standard-backups://backends/%s/options.schema.json", b.Name)
+}
+
+// compileOptionsSchema compiles the options schema shipped with the backend.
+// Returns nil if the backend does not have an options schema.
+func (b *BackendManifestV1) compileOptionsSchema() (*jsonschema.Schema, error) {
+	if b.OptionsSchema == nil {
+		return nil, nil
+	}
+	url := b.optionsSchemaUrl()
+	compiler := jsonschema.NewCompiler()
+	err := compiler.AddResource(url, b.OptionsSchema)
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// validateDestinationOptions checks the options of every destination against
+// the options schema of their backend. Destinations with variants are
+// validated once per variant with the merged options since that's what the
+// backend ends up receiving. Templates must already be applied so that values
+// are checked as the backend receives them.
+func (mc *MainConfig) validateDestinationOptions(backends []BackendManifestV1) []ValidationError {
+	res := []ValidationError{}
+
+	schemas := map[string]*jsonschema.Schema{}
+	for _, b := range backends {
+		schema, err := b.compileOptionsSchema()
+		if err != nil {
+			res = append(res, ValidationError{
+				File:      b.Path,
+				FieldPath: "/options-schema",
+				Err:       err,
+			})
+			continue
+		}
+		if schema != nil {
+			schemas[b.Name] = schema
+		}
+	}
+
+	destNames := make([]string, 0, len(mc.Destinations))
+	for name := range mc.Destinations {
+		destNames = append(destNames, name)
+	}
+	sort.Strings(destNames)
+
+	for _, destName := range destNames {
+		dest := mc.Destinations[destName]
+		schema, ok := schemas[dest.Backend]
+		if !ok {
+			continue
+		}
+
+		optionsPath := fmt.Sprintf("/destinations/%s/options", destName)
+		if len(dest.Variants) == 0 {
+			res = append(res, validateOptions(schema, dest.Options, optionsPath, "", nil)...)
+			continue
+		}
+
+		variantNames := make([]string, 0, len(dest.Variants))
+		for name := range dest.Variants {
+			variantNames = append(variantNames, name)
+		}
+		sort.Strings(variantNames)
+
+		for _, variantName := range variantNames {
+			merged, _, err := mc.GetDestination(fmt.Sprintf("%s/%s", destName, variantName))
+			if err != nil {
+				res = append(res, ValidationError{
+					File:      mc.path,
+					FieldPath: fmt.Sprintf("/destinations/%s/variants/%s", destName, variantName),
+					Err:       err,
+				})
+				continue
+			}
+			res = append(res, validateOptions(
+				schema,
+				merged.Options,
+				optionsPath,
+				variantName,
+				dest.Variants[variantName],
+			)...)
+		}
+	}
+
+	// Errors from validateOptions are about the main config
+	for i := range res {
+		if res[i].File == "" {
+			res[i].File = mc.path
+		}
+	}
+	return res
+}
+
+func validateOptions(
+	schema *jsonschema.Schema,
+	options map[string]any,
+	optionsPath string,
+	variantName string,
+	variant map[string]any,
+) []ValidationError {
+	var instance any = options
+	if options == nil {
+		instance = map[string]any{}
+	}
+
+	err := schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []ValidationError{{FieldPath: optionsPath, Err: err}}
+	}
+
+	variantPath := ""
+	if variantName != "" {
+		variantPath = strings.Replace(optionsPath, "/options", "/variants/"+variantName, 1)
+	}
+
+	// Causes come out in a random order, sort them to get stable output
+	leaves := validationErrorLeaves(validationErr)
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return jsonPointer(leaves[i].InstanceLocation) < jsonPointer(leaves[j].InstanceLocation)
+	})
+
+	res := []ValidationError{}
+	for _, leaf := range leaves {
+		fieldPath := optionsPath + jsonPointer(leaf.InstanceLocation)
+		var leafErr error = errors.New(leaf.ErrorKind.LocalizedString(optionsErrorPrinter))
+		if variantName != "" {
+			// Point at the variant when it's the one setting the bad value
+			if len(leaf.InstanceLocation) == 0 || hasPath(variant, leaf.InstanceLocation) {
+				fieldPath = variantPath + jsonPointer(leaf.InstanceLocation)
+			}
+			leafErr = fmt.Errorf("with variant %s: %w", variantName, leafErr)
+		}
+		res = append(res, ValidationError{FieldPath: fieldPath, Err: leafErr})
+	}
+	return res
+}
+
+func validationErrorLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	res := []*jsonschema.ValidationError{}
+	for _, cause := range err.Causes {
+		res = append(res, validationErrorLeaves(cause)...)
+	}
+	return res
+}
+
+func hasPath(value any, location []string) bool {
+	for _, part := range location {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		value, ok = m[part]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonPointer(location []string) string {
+	var sb strings.Builder
+	for _, part := range location {
+		sb.WriteByte('/')
+		part = strings.ReplaceAll(part, "~", "~0")
+		part = strings.ReplaceAll(part, "/", "~1")
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
@@ -9,7 +9,6 @@ import (
 	"path"
 	"strings"
 
-	"github.com/go-viper/mapstructure/v2"
 	"github.com/goccy/go-yaml"
 	"github.com/santhosh-tekuri/jsonschema/v6"
 )
@@ -130,7 +129,7 @@ func loadRecipeManifest(path string) (*RecipeManifestV1, error) {
 	}
 
 	var res RecipeManifestV1
-	err = mapstructure.Decode(rawManifest, &res)
+	err = decode(rawManifest, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode recipe manifest %s: %w", path, err)
 	}
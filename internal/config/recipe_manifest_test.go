@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/dotboris/standard-backups/internal/testutils"
 	"github.com/stretchr/testify/assert"
@@ -251,6 +252,31 @@ func TestLoadRecipeManifestsNoHooks(t *testing.T) {
 	}
 }
 
+func TestLoadRecipeManifestsHookTimeout(t *testing.T) {
+	d := t.TempDir()
+	p := path.Join(d, "app.yaml")
+	err := os.WriteFile(p,
+		[]byte(testutils.DedentYaml(`
+			version: 1
+			name: app
+			paths: [/app/to/backup]
+			before:
+				shell: bash
+				command: echo before
+				timeout: 1m30s
+		`)),
+		0o644)
+	require.NoError(t, err)
+	manifests, err := LoadRecipeManifests([]string{d})
+	if assert.NoError(t, err) && assert.Len(t, manifests, 1) {
+		assert.Equal(t, &HookV1{
+			Shell:   "bash",
+			Command: "echo before",
+			Timeout: 90 * time.Second,
+		}, manifests[0].Before)
+	}
+}
+
 func TestLoadRecipeManifestsInvalidEmptyFile(t *testing.T) {
 	d := t.TempDir()
 	err := os.WriteFile(path.Join(d, "app.yaml"), []byte(""), 0o644)
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const retrySchemaUrl = "standard-backups://retry.schema.json"
+
+var (
+	retrySchemaDoc = map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"attempts":      map[string]any{"type": "integer", "minimum": 1},
+			"initial-delay": durationSchema,
+			"max-delay":     durationSchema,
+			"multiplier":    map[string]any{"type": "number", "minimum": 1},
+			"jitter":        map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+		},
+	}
+	retrySchemaRef = map[string]any{"$ref": retrySchemaUrl}
+)
+
+func addRetrySchema(compiler *jsonschema.Compiler) error {
+	return compiler.AddResource(retrySchemaUrl, retrySchemaDoc)
+}
+
+// RetryV1 controls how many times a backup to a destination is attempted
+// before giving up and how long to wait between attempts.
+type RetryV1 struct {
+	// Total number of attempts, including the first one
+	Attempts     int           `mapstructure:"attempts"`
+	InitialDelay time.Duration `mapstructure:"initial-delay"`
+	MaxDelay     time.Duration `mapstructure:"max-delay"`
+	// Factor applied to the delay after every failed attempt
+	Multiplier float64 `mapstructure:"multiplier"`
+	// Fraction of the delay that's randomly added or removed (ex: 0.1 is ±10%)
+	Jitter float64 `mapstructure:"jitter"`
+}
+
+var (
+	// noRetry is used when neither the job nor the destination configure retries
+	noRetry      = RetryV1{Attempts: 1}
+	defaultRetry = RetryV1{
+		Attempts:     3,
+		InitialDelay: 10 * time.Second,
+		MaxDelay:     5 * time.Minute,
+		Multiplier:   2,
+	}
+)
+
+func (r RetryV1) withDefaults() RetryV1 {
+	if r.Attempts == 0 {
+		r.Attempts = defaultRetry.Attempts
+	}
+	if r.InitialDelay == 0 {
+		r.InitialDelay = defaultRetry.InitialDelay
+	}
+	if r.MaxDelay == 0 {
+		r.MaxDelay = defaultRetry.MaxDelay
+	}
+	if r.Multiplier == 0 {
+		r.Multiplier = defaultRetry.Multiplier
+	}
+	// No default for Jitter since 0 (no jitter) is a meaningful value
+	return r
+}
+
+// GetRetry returns the retry policy for backing up the given job to the given
+// destination. The destination's policy takes precedence over the job's.
+// Fields that are not set are filled in with defaults.
+func GetRetry(job JobConfigV1, dest DestinationConfigV1) RetryV1 {
+	switch {
+	case dest.Retry != nil:
+		return dest.Retry.withDefaults()
+	case job.Retry != nil:
+		return job.Retry.withDefaults()
+	default:
+		return noRetry
+	}
+}
+
+// Delay returns how long to wait before the given retry (1 is the first retry)
+// without jitter applied.
+func (r RetryV1) Delay(retry int) time.Duration {
+	delay := float64(r.InitialDelay)
+	for range retry - 1 {
+		delay *= r.Multiplier
+		if delay >= float64(r.MaxDelay) {
+			return r.MaxDelay
+		}
+	}
+	return min(time.Duration(delay), r.MaxDelay)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRetry(t *testing.T) {
+	jobRetry := &RetryV1{Attempts: 5}
+	destRetry := &RetryV1{Attempts: 2, InitialDelay: time.Second}
+	cases := []struct {
+		name     string
+		job      JobConfigV1
+		dest     DestinationConfigV1
+		expected RetryV1
+	}{
+		{
+			name:     "none",
+			expected: RetryV1{Attempts: 1},
+		},
+		{
+			name: "job",
+			job:  JobConfigV1{Retry: jobRetry},
+			expected: RetryV1{
+				Attempts:     5,
+				InitialDelay: 10 * time.Second,
+				MaxDelay:     5 * time.Minute,
+				Multiplier:   2,
+			},
+		},
+		{
+			name: "destination overrides job",
+			job:  JobConfigV1{Retry: jobRetry},
+			dest: DestinationConfigV1{Retry: destRetry},
+			expected: RetryV1{
+				Attempts:     2,
+				InitialDelay: time.Second,
+				MaxDelay:     5 * time.Minute,
+				Multiplier:   2,
+			},
+		},
+		{
+			name: "empty block uses defaults",
+			job:  JobConfigV1{Retry: &RetryV1{}},
+			expected: RetryV1{
+				Attempts:     3,
+				InitialDelay: 10 * time.Second,
+				MaxDelay:     5 * time.Minute,
+				Multiplier:   2,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, GetRetry(c.job, c.dest))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := RetryV1{
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   3,
+	}
+	assert.Equal(t, time.Second, r.Delay(1))
+	assert.Equal(t, 3*time.Second, r.Delay(2))
+	assert.Equal(t, 9*time.Second, r.Delay(3))
+	assert.Equal(t, 10*time.Second, r.Delay(4))
+	assert.Equal(t, 10*time.Second, r.Delay(100))
+}
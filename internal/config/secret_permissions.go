@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+)
+
+const (
+	// SecretPermissionsIgnore disables the checks on secret files
+	SecretPermissionsIgnore = "ignore"
+	// SecretPermissionsWarn logs a warning when secret files are unsafe
+	SecretPermissionsWarn = "warn"
+	// SecretPermissionsFail refuses to use secret files that are unsafe
+	SecretPermissionsFail = "fail"
+)
+
+// auditSecretFile returns the reasons why users other than the one running
+// standard-backups could read or replace the secret file at p.
+func auditSecretFile(p string) ([]error, error) {
+	res := []error{}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		res = append(res, fmt.Errorf(
+			"%s can be accessed by other users (mode %04o), only its owner should have access (ex: chmod 600 %s)",
+			p, perm, p))
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid := os.Geteuid()
+		if int(stat.Uid) != uid {
+			res = append(res, fmt.Errorf(
+				"%s is owned by uid %d instead of the user running standard-backups (uid %d)",
+				p, stat.Uid, uid))
+		}
+	}
+
+	dir := path.Dir(p)
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Only the owner of a file can replace it in a directory with the sticky bit
+	// set (ex: /tmp)
+	if dirInfo.Mode().Perm()&0o002 != 0 && dirInfo.Mode()&os.ModeSticky == 0 {
+		res = append(res, fmt.Errorf(
+			"%s is in %s which is writable by everyone, other users could replace it",
+			p, dir))
+	}
+
+	return res, nil
+}
+
+// checkSecretPermissions audits the file of a from-file secret and either
+// warns about the problems or fails depending on permissions.
+func checkSecretPermissions(name string, secret SecretConfigV1, permissions string) error {
+	if secret.FromFile == "" || permissions == SecretPermissionsIgnore {
+		return nil
+	}
+	problems, err := auditSecretFile(secret.FromFile)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions of secret %s: %w", name, err)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if permissions == SecretPermissionsFail {
+		return fmt.Errorf("secret %s is not safe: %w", name, errors.Join(problems...))
+	}
+	for _, problem := range problems {
+		slog.Warn("secret file is not safe",
+			slog.String("secret", name),
+			slog.Any("problem", problem))
+	}
+	return nil
+}
+
+// CheckSecretPermissions audits the files of from-file secrets before they get
+// used. Depending on secret-permissions, it either warns about the problems or
+// returns an error. Commands that pass secrets along should call this before
+// doing anything with them.
+func (c *Config) CheckSecretPermissions() error {
+	permissions := c.MainConfig.GetSecretPermissions()
+	names := make([]string, 0, len(c.MainConfig.Secrets))
+	for name := range c.MainConfig.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := checkSecretPermissions(name, c.MainConfig.Secrets[name], permissions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSecretPermissions reports the problems with the files of from-file
+// secrets. They're reported even when they would only cause a warning so that
+// they can be found with validate-config.
+func (mc *MainConfig) validateSecretPermissions() []ValidationError {
+	res := []ValidationError{}
+	if mc.GetSecretPermissions() == SecretPermissionsIgnore {
+		return res
+	}
+
+	names := make([]string, 0, len(mc.Secrets))
+	for name := range mc.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		secret := mc.Secrets[name]
+		if secret.FromFile == "" {
+			continue
+		}
+		fieldPath := fmt.Sprintf("/secrets/%s/from-file", name)
+		problems, err := auditSecretFile(secret.FromFile)
+		if err != nil {
+			problems = []error{err}
+		}
+		for _, problem := range problems {
+			res = append(res, ValidationError{
+				File:      mc.path,
+				FieldPath: fieldPath,
+				Err:       problem,
+			})
+		}
+	}
+	return res
+}
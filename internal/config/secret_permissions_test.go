@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecretFile(t *testing.T, dir string, mode os.FileMode) string {
+	p := path.Join(dir, "secret.txt")
+	err := os.WriteFile(p, []byte("supersecret"), 0o600)
+	require.NoError(t, err)
+	err = os.Chmod(p, mode)
+	require.NoError(t, err)
+	return p
+}
+
+func TestAuditSecretFileSafe(t *testing.T) {
+	p := writeSecretFile(t, t.TempDir(), 0o600)
+	problems, err := auditSecretFile(p)
+	if assert.NoError(t, err) {
+		assert.Empty(t, problems)
+	}
+}
+
+func TestAuditSecretFileReadableByOthers(t *testing.T) {
+	for _, mode := range []os.FileMode{0o640, 0o604, 0o660} {
+		t.Run(fmt.Sprintf("%04o", mode), func(t *testing.T) {
+			p := writeSecretFile(t, t.TempDir(), mode)
+			problems, err := auditSecretFile(p)
+			if assert.NoError(t, err) && assert.Len(t, problems, 1) {
+				assert.EqualError(t, problems[0], fmt.Sprintf(
+					"%s can be accessed by other users (mode %04o), only its owner should have access (ex: chmod 600 %s)",
+					p, mode, p))
+			}
+		})
+	}
+}
+
+func TestAuditSecretFileWorldWritableDir(t *testing.T) {
+	d := t.TempDir()
+	err := os.Chmod(d, 0o777)
+	require.NoError(t, err)
+	p := writeSecretFile(t, d, 0o600)
+	problems, err := auditSecretFile(p)
+	if assert.NoError(t, err) && assert.Len(t, problems, 1) {
+		assert.EqualError(t, problems[0], fmt.Sprintf(
+			"%s is in %s which is writable by everyone, other users could replace it",
+			p, d))
+	}
+}
+
+func TestAuditSecretFileMissing(t *testing.T) {
+	_, err := auditSecretFile(path.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestAuditSecretFileStickyDir(t *testing.T) {
+	d := t.TempDir()
+	err := os.Chmod(d, 0o777|os.ModeSticky)
+	require.NoError(t, err)
+	p := writeSecretFile(t, d, 0o600)
+	problems, err := auditSecretFile(p)
+	if assert.NoError(t, err) {
+		assert.Empty(t, problems)
+	}
+}
+
+func TestCheckSecretPermissions(t *testing.T) {
+	p := writeSecretFile(t, t.TempDir(), 0o644)
+	newConfig := func(permissions string) *Config {
+		return &Config{MainConfig: MainConfig{
+			SecretPermissions: permissions,
+			Secrets: map[string]SecretConfigV1{
+				"file":    {FromFile: p},
+				"literal": {Literal: "supersecret"},
+			},
+		}}
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		assert.NoError(t, newConfig(SecretPermissionsWarn).CheckSecretPermissions())
+	})
+	t.Run("ignore", func(t *testing.T) {
+		assert.NoError(t, newConfig(SecretPermissionsIgnore).CheckSecretPermissions())
+	})
+	t.Run("fail", func(t *testing.T) {
+		err := newConfig(SecretPermissionsFail).CheckSecretPermissions()
+		assert.EqualError(t, err, fmt.Sprintf(
+			"secret file is not safe: %s can be accessed by other users (mode 0644), only its owner should have access (ex: chmod 600 %s)",
+			p, p))
+	})
+}
+
+func TestLoadConfigUnsafeSecretFail(t *testing.T) {
+	// Unsafe secrets must not stop validate-config from reporting them
+	d := t.TempDir()
+	p := writeSecretFile(t, d, 0o644)
+	configPath := path.Join(d, "config.yaml")
+	err := os.WriteFile(configPath, []byte(fmt.Sprintf(`version: 1
+secret-permissions: fail
+secrets:
+  file:
+    from-file: %s
+`, p)), 0o600)
+	require.NoError(t, err)
+
+	c, err := LoadConfig(configPath, nil, nil)
+	require.NoError(t, err)
+	errs := c.Validate()
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "/secrets/file/from-file", errs[0].FieldPath)
+	}
+	assert.ErrorContains(t, c.CheckSecretPermissions(), "secret file is not safe")
+}
@@ -1,26 +1,122 @@
 package config
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/dotboris/standard-backups/internal/process"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/goccy/go-yaml"
+	"golang.org/x/text/transform"
+)
+
+const (
+	// SecretEncodingRaw is for secrets that are used as is
+	SecretEncodingRaw = "raw"
+	// SecretEncodingBase64 is for secrets that are base64 encoded
+	SecretEncodingBase64 = "base64"
 )
 
+// secretSources are the ways to load secrets. Every secret uses exactly one.
+var secretSources = []struct {
+	name   string
+	schema map[string]any
+}{
+	{"from-file", map[string]any{"type": "string"}},
+	{"literal", map[string]any{"type": "string"}},
+	{"from-env", map[string]any{"type": "string", "minLength": 1}},
+	{"from-command", hookSchemaRef},
+	{"from-systemd-credential", map[string]any{
+		"type":    "string",
+		"pattern": `^[^/]+$`,
+		"not":     map[string]any{"enum": []any{".", ".."}},
+	}},
+	{"from-encrypted-file", map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []any{"path", "identity"},
+		"properties": map[string]any{
+			"path":     map[string]any{"type": "string", "minLength": 1},
+			"key":      map[string]any{"type": "string", "minLength": 1},
+			"identity": map[string]any{"type": "string", "minLength": 1},
+		},
+	}},
+}
+
+var secretSchema = func() map[string]any {
+	properties := map[string]any{
+		"trim":       map[string]any{"type": "boolean"},
+		"encoding":   map[string]any{"enum": []any{SecretEncodingRaw, SecretEncodingBase64}},
+		"min-length": map[string]any{"type": "integer", "minimum": 1},
+	}
+	oneOf := []any{}
+	for _, source := range secretSources {
+		properties[source.name] = source.schema
+		oneOf = append(oneOf, map[string]any{"required": []any{source.name}})
+	}
+	return map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+		"oneOf":                oneOf,
+	}
+}()
+
 var errLoadSecretUnimplemented = errors.New("config.loadSecret: unimplemented")
 
+// defaultSecretCommandTimeout is how long from-command secrets get to run when
+// they don't set a timeout. Unlike hooks, they can't run forever since they
+// block everything else.
+const defaultSecretCommandTimeout = 30 * time.Second
+
+// credentialsDirectoryEnv is set by systemd to the directory holding the
+// credentials of the service.
+const credentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
 func loadSecrets(secretsConfig map[string]SecretConfigV1) (map[string]string, error) {
 	res := map[string]string{}
-	for key, c := range secretsConfig {
-		value, err := loadSecret(c)
+	// What commands print on stderr can only be redacted once all secrets are
+	// known. It's held until then.
+	stderr := bytes.Buffer{}
+	defer func() {
+		if stderr.Len() > 0 {
+			_, _ = os.Stderr.WriteString(redactSecrets(res, stderr.String()))
+		}
+	}()
+	keys := make([]string, 0, len(secretsConfig))
+	for key := range secretsConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := loadSecret(secretsConfig[key], &stderr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load secret %s: %w", key, err)
 		}
+		value, err = processSecret(secretsConfig[key], value)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s is invalid: %w", key, err)
+		}
 		res[key] = value
 	}
 	return res, nil
 }
 
-func loadSecret(secret SecretConfigV1) (string, error) {
+func loadSecret(secret SecretConfigV1, stderr *bytes.Buffer) (string, error) {
 	if secret.FromFile != "" {
 		res, err := os.ReadFile(secret.FromFile)
 		if err != nil {
@@ -29,7 +125,184 @@ func loadSecret(secret SecretConfigV1) (string, error) {
 		return string(res), nil
 	} else if secret.Literal != "" {
 		return secret.Literal, nil
+	} else if secret.FromEnv != "" {
+		res, ok := os.LookupEnv(secret.FromEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", secret.FromEnv)
+		}
+		return res, nil
+	} else if secret.FromCommand != nil {
+		return loadSecretFromCommand(*secret.FromCommand, stderr)
+	} else if secret.FromSystemdCredential != "" {
+		return loadSecretFromSystemdCredential(secret.FromSystemdCredential)
+	} else if secret.FromEncryptedFile != nil {
+		return loadSecretFromEncryptedFile(*secret.FromEncryptedFile)
 	} else {
 		return "", errLoadSecretUnimplemented
 	}
 }
+
+// processSecret applies the trim and encoding options to a loaded secret and
+// checks that the result is usable. Empty secrets are rejected since they can't
+// be redacted.
+func processSecret(secret SecretConfigV1, value string) (string, error) {
+	if secret.Trim == nil || *secret.Trim {
+		value = strings.TrimSpace(value)
+	}
+	if secret.Encoding == SecretEncodingBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64: %w", err)
+		}
+		value = string(decoded)
+	}
+	if value == "" {
+		return "", errors.New("value is empty")
+	}
+	length := utf8.RuneCountInString(value)
+	if length < secret.MinLength {
+		return "", fmt.Errorf("value has %d characters, expected at least %d", length, secret.MinLength)
+	}
+	return value, nil
+}
+
+// loadSecretFromCommand returns what the command prints on stdout. Like with
+// $(...) in shells, trailing newlines are removed.
+//
+// Unlike hooks and backends, the command stays in the foreground process group
+// and gets stdin so that password managers (ex: pass through a GPG pinentry,
+// op read) can prompt for a passphrase.
+func loadSecretFromCommand(hook HookV1, stderr *bytes.Buffer) (string, error) {
+	name, args, err := hook.ShellCommand()
+	if err != nil {
+		return "", err
+	}
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = defaultSecretCommandTimeout
+	}
+	ctx, cancel := process.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Don't wait forever on stdout if something the command started holds on to
+	// it after it's killed.
+	cmd.WaitDelay = time.Second
+	stdout := bytes.Buffer{}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	err = process.Err(ctx, cmd.Run())
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// loadSecretFromSystemdCredential reads a credential passed by systemd with
+// LoadCredential= or LoadCredentialEncrypted=. systemd decrypts credentials and
+// puts them in $CREDENTIALS_DIRECTORY before starting the service.
+func loadSecretFromSystemdCredential(name string) (string, error) {
+	dir := os.Getenv(credentialsDirectoryEnv)
+	if dir == "" {
+		return "", fmt.Errorf(
+			"cannot load systemd credential %s, %s is not set (is standard-backups running in a systemd service with LoadCredential=%s?)",
+			name, credentialsDirectoryEnv, name)
+	}
+	res, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return string(res), nil
+}
+
+func loadSecretFromEncryptedFile(ef SecretEncryptedFileV1) (string, error) {
+	plaintext, err := decryptAgeFile(ef.Path, ef.Identity)
+	if err != nil {
+		return "", err
+	}
+	if ef.Key == "" {
+		return string(plaintext), nil
+	}
+	values := map[string]any{}
+	err = yaml.Unmarshal(plaintext, &values)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse decrypted file %s: %w", ef.Path, err)
+	}
+	value, ok := values[ef.Key]
+	if !ok {
+		return "", fmt.Errorf("could not find key %s in encrypted file %s", ef.Key, ef.Path)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %s in encrypted file %s is not a string", ef.Key, ef.Path)
+	}
+	return str, nil
+}
+
+func decryptAgeFile(p string, identityPath string) ([]byte, error) {
+	identityFile, err := os.Open(identityPath)
+	if err != nil {
+		return nil, err
+	}
+	defer identityFile.Close()
+	identities, err := age.ParseIdentities(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity file %s: %w", identityPath, err)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Encrypted files can be binary or ASCII armored (age --armor)
+	r := bufio.NewReader(f)
+	var in io.Reader = r
+	start, _ := r.Peek(len(armor.Header))
+	if string(start) == armor.Header {
+		in = armor.NewReader(r)
+	}
+	plaintext, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", p, err)
+	}
+	return io.ReadAll(plaintext)
+}
+
+// resolveSecretPaths makes the paths of encrypted files relative to the main
+// config so that they can be stored alongside it.
+func (mc *MainConfig) resolveSecretPaths() {
+	dir := path.Dir(mc.path)
+	for name, secret := range mc.Secrets {
+		ef := secret.FromEncryptedFile
+		if ef == nil {
+			continue
+		}
+		if !path.IsAbs(ef.Path) {
+			ef.Path = path.Join(dir, ef.Path)
+		}
+		if !path.IsAbs(ef.Identity) {
+			ef.Identity = path.Join(dir, ef.Identity)
+		}
+		mc.Secrets[name] = secret
+	}
+}
+
+// redactSecrets removes the given secrets from s.
+func redactSecrets(secrets map[string]string, s string) string {
+	values := []string{}
+	for _, value := range secrets {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	t, err := redact.NewTransformer(values...)
+	if err != nil {
+		return redact.REPLACE
+	}
+	res, _, err := transform.String(t, s)
+	if err != nil {
+		return redact.REPLACE
+	}
+	return res
+}
@@ -1,16 +1,24 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"syscall"
 	"testing"
+	"time"
 
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestLoadSecretLiteral(t *testing.T) {
-	res, err := loadSecret(SecretConfigV1{Literal: "supersecret"})
+	res, err := loadSecret(SecretConfigV1{Literal: "supersecret"}, nil)
 	if assert.NoError(t, err) {
 		assert.Equal(t, "supersecret", res)
 	}
@@ -20,13 +28,234 @@ func TestLoadSecretFromFile(t *testing.T) {
 	file := path.Join(t.TempDir(), "my-secret.txt")
 	err := os.WriteFile(file, []byte("supersecret from file"), 0o600)
 	require.NoError(t, err)
-	res, err := loadSecret(SecretConfigV1{FromFile: file})
+	res, err := loadSecret(SecretConfigV1{FromFile: file}, nil)
 	if assert.NoError(t, err) {
 		assert.Equal(t, "supersecret from file", res)
 	}
 }
 
 func TestLoadSecretFromFileNotFound(t *testing.T) {
-	_, err := loadSecret(SecretConfigV1{FromFile: "does-not-exist.txt"})
+	_, err := loadSecret(SecretConfigV1{FromFile: "does-not-exist.txt"}, nil)
 	assert.ErrorIs(t, err, os.ErrNotExist)
 }
+
+func TestLoadSecretFromEnv(t *testing.T) {
+	t.Setenv("MY_SECRET", "supersecret from env")
+	res, err := loadSecret(SecretConfigV1{FromEnv: "MY_SECRET"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "supersecret from env", res)
+	}
+}
+
+func TestLoadSecretFromEnvNotSet(t *testing.T) {
+	_, err := loadSecret(SecretConfigV1{FromEnv: "STANDARD_BACKUPS_DOES_NOT_EXIST"}, nil)
+	assert.EqualError(t, err, "environment variable STANDARD_BACKUPS_DOES_NOT_EXIST is not set")
+}
+
+func TestLoadSecretFromCommand(t *testing.T) {
+	stderr := bytes.Buffer{}
+	res, err := loadSecret(SecretConfigV1{
+		FromCommand: &HookV1{Shell: "bash", Command: "echo 'unlocking' >&2; printf 'supersecret\\n\\n'"},
+	}, &stderr)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "supersecret", res)
+		assert.Equal(t, "unlocking\n", stderr.String())
+	}
+}
+
+func TestLoadSecretFromCommandFails(t *testing.T) {
+	_, err := loadSecret(SecretConfigV1{
+		FromCommand: &HookV1{Shell: "sh", Command: "exit 3"},
+	}, &bytes.Buffer{})
+	assert.EqualError(t, err, "command failed: exit status 3")
+}
+
+func TestLoadSecretFromCommandTimeout(t *testing.T) {
+	_, err := loadSecret(SecretConfigV1{
+		FromCommand: &HookV1{Shell: "sh", Command: "sleep 10", Timeout: 100 * time.Millisecond},
+	}, &bytes.Buffer{})
+	var timeoutErr *process.TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	res := redactSecrets(
+		map[string]string{"a": "hunter2", "b": ""},
+		"the password is hunter2\n",
+	)
+	assert.NotContains(t, res, "hunter2")
+	assert.Contains(t, res, "the password is ")
+}
+
+func TestLoadSecretFromSystemdCredential(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(path.Join(dir, "restic-password"), []byte("supersecret"), 0o400)
+	require.NoError(t, err)
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	res, err := loadSecret(SecretConfigV1{FromSystemdCredential: "restic-password"}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "supersecret", res)
+	}
+
+	_, err = loadSecret(SecretConfigV1{FromSystemdCredential: "nope"}, nil)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadSecretFromSystemdCredentialNotInService(t *testing.T) {
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	_, err := loadSecret(SecretConfigV1{FromSystemdCredential: "restic-password"}, nil)
+	assert.ErrorContains(t, err, "CREDENTIALS_DIRECTORY is not set")
+}
+
+// writeAgeFile encrypts content with a new age identity. It returns the path
+// of the encrypted file and the path of the identity file.
+func writeAgeFile(t *testing.T, content string, armored bool) (string, string) {
+	t.Helper()
+	d := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	identityPath := path.Join(d, "key.txt")
+	err = os.WriteFile(identityPath, []byte(identity.String()+"\n"), 0o600)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	var out io.Writer = &buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(&buf)
+		out = armorWriter
+	}
+	w, err := age.Encrypt(out, identity.Recipient())
+	require.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	if armorWriter != nil {
+		require.NoError(t, armorWriter.Close())
+	}
+	filePath := path.Join(d, "secrets.age")
+	err = os.WriteFile(filePath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+	return filePath, identityPath
+}
+
+func TestLoadSecretFromEncryptedFile(t *testing.T) {
+	for _, armored := range []bool{false, true} {
+		t.Run(fmt.Sprintf("armored=%v", armored), func(t *testing.T) {
+			p, identity := writeAgeFile(t, "password: supersecret\nother: nope\n", armored)
+			res, err := loadSecret(SecretConfigV1{
+				FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Key: "password", Identity: identity},
+			}, nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "supersecret", res)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFromEncryptedFileJSON(t *testing.T) {
+	p, identity := writeAgeFile(t, `{"password": "supersecret"}`, false)
+	res, err := loadSecret(SecretConfigV1{
+		FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Key: "password", Identity: identity},
+	}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "supersecret", res)
+	}
+}
+
+func TestLoadSecretFromEncryptedFileWhole(t *testing.T) {
+	p, identity := writeAgeFile(t, "supersecret\n", true)
+	res, err := loadSecret(SecretConfigV1{
+		FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Identity: identity},
+	}, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "supersecret\n", res)
+	}
+}
+
+func TestLoadSecretFromEncryptedFileErrors(t *testing.T) {
+	p, identity := writeAgeFile(t, "password: supersecret\nport: 22\n", false)
+	_, otherIdentity := writeAgeFile(t, "", false)
+
+	_, err := loadSecret(SecretConfigV1{
+		FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Key: "nope", Identity: identity},
+	}, nil)
+	assert.EqualError(t, err, fmt.Sprintf("could not find key nope in encrypted file %s", p))
+
+	_, err = loadSecret(SecretConfigV1{
+		FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Key: "port", Identity: identity},
+	}, nil)
+	assert.EqualError(t, err, fmt.Sprintf("key port in encrypted file %s is not a string", p))
+
+	_, err = loadSecret(SecretConfigV1{
+		FromEncryptedFile: &SecretEncryptedFileV1{Path: p, Key: "password", Identity: otherIdentity},
+	}, nil)
+	var noMatch *age.NoIdentityMatchError
+	assert.ErrorAs(t, err, &noMatch)
+}
+
+func TestProcessSecret(t *testing.T) {
+	noTrim := false
+	for _, tc := range []struct {
+		name   string
+		secret SecretConfigV1
+		value  string
+		want   string
+		err    string
+	}{
+		{"trims by default", SecretConfigV1{}, "  pw\n", "pw", ""},
+		{"no trim", SecretConfigV1{Trim: &noTrim}, "pw\n", "pw\n", ""},
+		{"raw", SecretConfigV1{Encoding: SecretEncodingRaw}, "cHc=", "cHc=", ""},
+		{"base64", SecretConfigV1{Encoding: SecretEncodingBase64}, "cHc=\n", "pw", ""},
+		{"bad base64", SecretConfigV1{Encoding: SecretEncodingBase64}, "nope!", "", "invalid base64: illegal base64 data at input byte 4"},
+		{"empty", SecretConfigV1{}, "\n", "", "value is empty"},
+		{"min length", SecretConfigV1{MinLength: 3}, "pwé", "pwé", ""},
+		{"too short", SecretConfigV1{MinLength: 4}, "pwé", "", "value has 3 characters, expected at least 4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := processSecret(tc.secret, tc.value)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tc.want, res)
+			}
+		})
+	}
+}
+
+func TestLoadSecretsNamesInvalidSecret(t *testing.T) {
+	_, err := loadSecrets(map[string]SecretConfigV1{
+		"good": {Literal: "supersecret"},
+		"bad":  {Literal: "pw", MinLength: 8},
+	})
+	assert.EqualError(t, err, "secret bad is invalid: value has 2 characters, expected at least 8")
+}
+
+func TestLoadSecretFromCommandForeground(t *testing.T) {
+	// Password managers prompt through stdin and the terminal, the command must
+	// get both.
+	stdinPath := path.Join(t.TempDir(), "stdin")
+	err := os.WriteFile(stdinPath, []byte("typed secret\n"), 0o600)
+	require.NoError(t, err)
+	stdin, err := os.Open(stdinPath)
+	require.NoError(t, err)
+	defer stdin.Close()
+	realStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = realStdin }()
+
+	res, err := loadSecret(SecretConfigV1{
+		FromCommand: &HookV1{Shell: "sh", Command: `read line; echo "$line $(ps -o pgid= $$ | tr -d ' ')"`},
+	}, &bytes.Buffer{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, fmt.Sprintf("typed secret %d", syscall.Getpgrp()), res)
+	}
+}
+
+func TestLoadSecretFromCommandUnsupportedShell(t *testing.T) {
+	_, err := loadSecret(SecretConfigV1{
+		FromCommand: &HookV1{Shell: "fish", Command: "echo nope"},
+	}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrUnsupportedShell)
+}
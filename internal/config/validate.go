@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+
+	"github.com/dotboris/standard-backups/internal/schedule"
 )
 
 type ValidationError struct {
@@ -56,7 +58,20 @@ func (c *Config) Validate() []ValidationError {
 		}
 	}
 
+	res = append(res, c.MainConfig.validateDestinationOptions(c.Backends)...)
+	res = append(res, c.MainConfig.validateSecretPermissions()...)
+
 	for jobName, job := range c.MainConfig.Jobs {
+		if job.Schedule != "" {
+			_, err := schedule.Parse(job.Schedule)
+			if err != nil {
+				res = append(res, ValidationError{
+					File:      c.MainConfig.path,
+					FieldPath: fmt.Sprintf("/jobs/%s/schedule", jobName),
+					Err:       err,
+				})
+			}
+		}
 		for destIndex, destName := range job.BackupTo {
 			_, _, err := c.MainConfig.GetDestination(destName)
 			if err != nil {
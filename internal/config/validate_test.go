@@ -52,6 +52,7 @@ func TestValidateSuccess(t *testing.T) {
 				"j": {
 					Recipe:   "r",
 					BackupTo: []string{"d", "d/foo"},
+					Schedule: "0 3 * * *",
 				},
 			},
 		},
@@ -201,3 +202,81 @@ func TestValidateDefaultVariantBadRef(t *testing.T) {
 	assert.Equal(t, "/destinations/my-dest/default-variant", res[0].FieldPath)
 	assert.EqualError(t, res[0].Err, "unknown variant nope for destination my-dest")
 }
+
+func TestValidateDestinationOptionsSchema(t *testing.T) {
+	c := Config{
+		Backends: []BackendManifestV1{
+			{
+				Path:    "bogus/backends.d/b.yaml",
+				Name:    "b",
+				Bin:     "/bin/sh",
+				Version: 1,
+				OptionsSchema: map[string]any{
+					"type":     "object",
+					"required": []any{"repo"},
+				},
+			},
+		},
+		MainConfig: MainConfig{
+			path: "bogus/config.yaml",
+			Destinations: map[string]DestinationConfigV1{
+				"d": {Backend: "b"},
+			},
+		},
+	}
+
+	res := c.Validate()
+	require.Len(t, res, 1)
+	assert.Equal(t, "bogus/config.yaml", res[0].File)
+	assert.Equal(t, "/destinations/d/options", res[0].FieldPath)
+	assert.EqualError(t, res[0].Err, "missing property 'repo'")
+}
+
+func TestValidateBadSchedule(t *testing.T) {
+	c := Config{
+		MainConfig: MainConfig{
+			path: "bogus/config.yaml",
+			Jobs: map[string]JobConfigV1{
+				"j": {Recipe: "r", Schedule: "0 25 * * *"},
+			},
+		},
+	}
+	res := c.Validate()
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, "bogus/config.yaml", res[0].File)
+		assert.Equal(t, "/jobs/j/schedule", res[0].FieldPath)
+		assert.EqualError(t, res[0].Err,
+			`invalid schedule "0 25 * * *": hour must be between 0 and 23, got 25`)
+	}
+}
+
+func TestValidateSecretPermissions(t *testing.T) {
+	d := t.TempDir()
+	safe := path.Join(d, "safe.txt")
+	require.NoError(t, os.WriteFile(safe, []byte("pw"), 0o600))
+	unsafe := path.Join(d, "unsafe.txt")
+	require.NoError(t, os.WriteFile(unsafe, []byte("pw"), 0o600))
+	require.NoError(t, os.Chmod(unsafe, 0o644))
+
+	c := Config{
+		MainConfig: MainConfig{
+			path: "bogus/config.yaml",
+			Secrets: map[string]SecretConfigV1{
+				"safe":    {FromFile: safe},
+				"unsafe":  {FromFile: unsafe},
+				"literal": {Literal: "pw"},
+			},
+		},
+	}
+	res := c.Validate()
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, "bogus/config.yaml", res[0].File)
+		assert.Equal(t, "/secrets/unsafe/from-file", res[0].FieldPath)
+		assert.EqualError(t, res[0].Err, fmt.Sprintf(
+			"%s can be accessed by other users (mode 0644), only its owner should have access (ex: chmod 600 %s)",
+			unsafe, unsafe))
+	}
+
+	c.MainConfig.SecretPermissions = SecretPermissionsIgnore
+	assert.Empty(t, c.Validate())
+}
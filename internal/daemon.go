@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/schedule"
+)
+
+type (
+	// Daemon runs jobs at the times given by their schedule.
+	Daemon struct {
+		// loadConfig loads the config when the daemon starts and every time
+		// it's asked to reload it.
+		loadConfig func() (*config.Config, error)
+		backup     func(ctx context.Context, cfg config.Config, jobName string) (*JobResult, error)
+		now        func() time.Time
+		after      func(d time.Duration) <-chan time.Time
+	}
+	scheduledJob struct {
+		schedule *schedule.Schedule
+		next     time.Time
+	}
+)
+
+func NewDaemon(loadConfig func() (*config.Config, error)) *Daemon {
+	backupSvc := NewBackupService()
+	return &Daemon{
+		loadConfig: loadConfig,
+		backup:     backupSvc.Backup,
+		now:        time.Now,
+		after:      time.After,
+	}
+}
+
+// Run runs jobs on their schedule until ctx is done. The config is reloaded
+// every time reload receives a signal. Running jobs keep using the config they
+// started with. When ctx is done, running jobs are canceled and Run waits for
+// them to stop.
+func (d *Daemon) Run(ctx context.Context, reload <-chan os.Signal) error {
+	cfg, jobs, err := d.load()
+	if err != nil {
+		return err
+	}
+	slog.Info("daemon started")
+
+	running := map[string]bool{}
+	done := make(chan string)
+	for {
+		var timer <-chan time.Time
+		if next := nextRun(jobs); !next.IsZero() {
+			timer = d.after(next.Sub(d.now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("stopping daemon", slog.Int("runningJobs", len(running)))
+			for len(running) > 0 {
+				delete(running, <-done)
+			}
+			return nil
+		case <-reload:
+			newCfg, newJobs, err := d.load()
+			if err != nil {
+				slog.Error("failed to reload config, keeping the previous one",
+					slog.Any("error", err))
+				continue
+			}
+			cfg, jobs = newCfg, newJobs
+			slog.Info("reloaded config")
+		case jobName := <-done:
+			delete(running, jobName)
+		case <-timer:
+			now := d.now()
+			for _, jobName := range sortedJobNames(jobs) {
+				job := jobs[jobName]
+				if job.next.After(now) {
+					continue
+				}
+				job.next = job.schedule.Next(now)
+				logger := slog.With(slog.String("job", jobName))
+				if running[jobName] {
+					logger.Warn("skipping scheduled run since the job is still running",
+						slog.Time("next", job.next))
+					continue
+				}
+				running[jobName] = true
+				go func(cfg config.Config) {
+					logger.Info("starting scheduled backup")
+					_, err := d.backup(ctx, cfg, jobName)
+					if err != nil {
+						logger.Error("scheduled backup failed", slog.Any("error", err))
+					} else {
+						logger.Info("scheduled backup completed")
+					}
+					done <- jobName
+				}(*cfg)
+			}
+		}
+	}
+}
+
+// load loads the config and computes the next run of every scheduled job.
+func (d *Daemon) load() (*config.Config, map[string]*scheduledJob, error) {
+	cfg, err := d.loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := d.now()
+	jobs := map[string]*scheduledJob{}
+	for jobName, job := range cfg.MainConfig.Jobs {
+		if job.Schedule == "" {
+			continue
+		}
+		s, err := schedule.Parse(job.Schedule)
+		if err != nil {
+			return nil, nil, fmt.Errorf("job %s has an invalid schedule: %w", jobName, err)
+		}
+		jobs[jobName] = &scheduledJob{schedule: s, next: s.Next(now)}
+	}
+
+	if len(jobs) == 0 {
+		slog.Warn("no jobs have a schedule, nothing will run")
+	}
+	for _, jobName := range sortedJobNames(jobs) {
+		slog.Info("scheduled job",
+			slog.String("job", jobName),
+			slog.String("schedule", cfg.MainConfig.Jobs[jobName].Schedule),
+			slog.Time("next", jobs[jobName].next))
+	}
+	return cfg, jobs, nil
+}
+
+// nextRun returns the earliest time at which a job needs to run. It returns the
+// zero time when no job needs to run.
+func nextRun(jobs map[string]*scheduledJob) time.Time {
+	res := time.Time{}
+	for _, job := range jobs {
+		if job.next.IsZero() {
+			continue
+		}
+		if res.IsZero() || job.next.Before(res) {
+			res = job.next
+		}
+	}
+	return res
+}
+
+func sortedJobNames(jobs map[string]*scheduledJob) []string {
+	res := make([]string, 0, len(jobs))
+	for name := range jobs {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
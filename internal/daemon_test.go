@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDaemon drives a daemon with a fake clock. Every time the daemon waits
+// for its next run, the timer shows up on timers in order.
+type fakeDaemon struct {
+	*Daemon
+	mu     sync.Mutex
+	now    time.Time
+	timers chan fakeTimer
+	calls  chan string
+	// Scheduled backups block until they receive from release
+	release chan struct{}
+}
+
+type fakeTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+func newFakeDaemon(t *testing.T, cfgs ...*config.Config) *fakeDaemon {
+	fd := &fakeDaemon{
+		now:     time.Date(2025, 1, 15, 10, 0, 30, 0, time.UTC),
+		timers:  make(chan fakeTimer, 100),
+		calls:   make(chan string, 10),
+		release: make(chan struct{}),
+	}
+	loads := 0
+	fd.Daemon = &Daemon{
+		loadConfig: func() (*config.Config, error) {
+			if loads >= len(cfgs) {
+				return nil, errors.New("bad config")
+			}
+			cfg := cfgs[loads]
+			loads++
+			return cfg, nil
+		},
+		backup: func(ctx context.Context, cfg config.Config, jobName string) (*JobResult, error) {
+			fd.calls <- jobName
+			select {
+			case <-fd.release:
+			case <-ctx.Done():
+			}
+			return &JobResult{Job: jobName}, nil
+		},
+		now: func() time.Time {
+			fd.mu.Lock()
+			defer fd.mu.Unlock()
+			return fd.now
+		},
+		after: func(d time.Duration) <-chan time.Time {
+			c := make(chan time.Time, 1)
+			fd.timers <- fakeTimer{d: d, c: c}
+			return c
+		},
+	}
+	return fd
+}
+
+func (fd *fakeDaemon) nextTimer(t *testing.T) fakeTimer {
+	t.Helper()
+	select {
+	case timer := <-fd.timers:
+		return timer
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for the daemon to set a timer")
+		return fakeTimer{}
+	}
+}
+
+// fire moves the clock to the given time and fires the timer
+func (fd *fakeDaemon) fire(timer fakeTimer, now time.Time) {
+	fd.mu.Lock()
+	fd.now = now
+	fd.mu.Unlock()
+	timer.c <- now
+}
+
+func (fd *fakeDaemon) nextCall(t *testing.T) string {
+	t.Helper()
+	select {
+	case jobName := <-fd.calls:
+		return jobName
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for a backup")
+		return ""
+	}
+}
+
+func daemonTestConfig(schedules map[string]string) *config.Config {
+	jobs := map[string]config.JobConfigV1{}
+	for name, s := range schedules {
+		jobs[name] = config.JobConfigV1{Recipe: "r", Schedule: s}
+	}
+	return &config.Config{MainConfig: config.MainConfig{Jobs: jobs}}
+}
+
+func TestDaemonRunsScheduledJobs(t *testing.T) {
+	fd := newFakeDaemon(t, daemonTestConfig(map[string]string{
+		"every-minute": "* * * * *",
+		"hourly":       "0 * * * *",
+		"manual":       "",
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	res := make(chan error)
+	go func() { res <- fd.Run(ctx, nil) }()
+
+	timer := fd.nextTimer(t)
+	assert.Equal(t, 30*time.Second, timer.d)
+	fd.fire(timer, time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC))
+	assert.Equal(t, "every-minute", fd.nextCall(t))
+	fd.release <- struct{}{}
+
+	// Timer set after starting the job, then after it completes
+	fd.nextTimer(t)
+	timer = fd.nextTimer(t)
+	assert.Equal(t, time.Minute, timer.d)
+	fd.fire(timer, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC))
+	calls := []string{fd.nextCall(t), fd.nextCall(t)}
+	assert.ElementsMatch(t, []string{"every-minute", "hourly"}, calls)
+
+	cancel()
+	assert.NoError(t, <-res)
+	assert.Empty(t, fd.calls)
+}
+
+func TestDaemonPreventsOverlap(t *testing.T) {
+	fd := newFakeDaemon(t, daemonTestConfig(map[string]string{
+		"slow": "* * * * *",
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	res := make(chan error)
+	go func() { res <- fd.Run(ctx, nil) }()
+
+	fd.fire(fd.nextTimer(t), time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC))
+	assert.Equal(t, "slow", fd.nextCall(t))
+
+	// Still running a minute later
+	fd.fire(fd.nextTimer(t), time.Date(2025, 1, 15, 10, 2, 0, 0, time.UTC))
+	timer := fd.nextTimer(t)
+	assert.Equal(t, time.Minute, timer.d)
+	assert.Empty(t, fd.calls)
+
+	// Runs again once the previous run is done
+	fd.release <- struct{}{}
+	timer = fd.nextTimer(t)
+	fd.fire(timer, time.Date(2025, 1, 15, 10, 3, 0, 0, time.UTC))
+	assert.Equal(t, "slow", fd.nextCall(t))
+
+	cancel()
+	assert.NoError(t, <-res)
+}
+
+func TestDaemonWaitsForRunningJobs(t *testing.T) {
+	fd := newFakeDaemon(t, daemonTestConfig(map[string]string{
+		"my-job": "* * * * *",
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	res := make(chan error)
+	go func() { res <- fd.Run(ctx, nil) }()
+
+	fd.fire(fd.nextTimer(t), time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC))
+	assert.Equal(t, "my-job", fd.nextCall(t))
+	fd.nextTimer(t)
+
+	// The fake backup returns once ctx is canceled
+	cancel()
+	assert.NoError(t, <-res)
+}
+
+func TestDaemonReload(t *testing.T) {
+	fd := newFakeDaemon(t,
+		daemonTestConfig(map[string]string{"a": "0 12 * * *"}),
+		daemonTestConfig(map[string]string{"b": "* * * * *"}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	reload := make(chan os.Signal)
+	res := make(chan error)
+	go func() { res <- fd.Run(ctx, reload) }()
+
+	timer := fd.nextTimer(t)
+	assert.Equal(t, 2*time.Hour-30*time.Second, timer.d)
+
+	reload <- syscall.SIGHUP
+	timer = fd.nextTimer(t)
+	assert.Equal(t, 30*time.Second, timer.d)
+
+	// The third load fails. The previous config is kept.
+	reload <- syscall.SIGHUP
+	timer = fd.nextTimer(t)
+	assert.Equal(t, 30*time.Second, timer.d)
+
+	fd.fire(timer, time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC))
+	assert.Equal(t, "b", fd.nextCall(t))
+	fd.release <- struct{}{}
+	fd.nextTimer(t)
+	fd.nextTimer(t)
+
+	cancel()
+	assert.NoError(t, <-res)
+}
+
+func TestDaemonInvalidSchedule(t *testing.T) {
+	fd := newFakeDaemon(t, daemonTestConfig(map[string]string{"a": "nope"}))
+	err := fd.Run(context.Background(), nil)
+	assert.ErrorContains(t, err, "job a has an invalid schedule")
+}
+
+func TestDaemonNoScheduledJobs(t *testing.T) {
+	fd := newFakeDaemon(t, daemonTestConfig(map[string]string{"a": ""}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// No timer is set since nothing needs to run
+	assert.NoError(t, fd.Run(ctx, nil))
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/schedule"
+	"github.com/dotboris/standard-backups/internal/status"
+)
+
+// NextDue returns when the given job is next due based on its last successful
+// run. A job is due once its interval has elapsed or once its schedule has
+// come up since its last success, whichever comes first. The zero time means
+// that the job is due right away. This is the case for jobs that never
+// succeeded and for jobs with neither a schedule nor an interval.
+func NextDue(mc config.MainConfig, jobName string) (time.Time, error) {
+	job, ok := mc.Jobs[jobName]
+	if !ok {
+		return time.Time{}, fmt.Errorf("could not find a job named %s", jobName)
+	}
+	js, err := status.NewStore(mc.GetStateDir()).Load(jobName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if js == nil || js.LastSuccess == nil {
+		return time.Time{}, nil
+	}
+	// Schedules are in local time
+	lastSuccess := js.LastSuccess.Local()
+
+	res := time.Time{}
+	if job.Interval > 0 {
+		res = lastSuccess.Add(job.Interval)
+	}
+	if job.Schedule != "" {
+		s, err := schedule.Parse(job.Schedule)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("job %s has an invalid schedule: %w", jobName, err)
+		}
+		next := s.Next(lastSuccess)
+		if !next.IsZero() && (res.IsZero() || next.Before(res)) {
+			res = next
+		}
+	}
+	return res, nil
+}
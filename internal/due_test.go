@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/dotboris/standard-backups/internal/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDue(t *testing.T) {
+	stateDir := t.TempDir()
+	lastSuccess := time.Date(2025, 1, 15, 10, 30, 0, 0, time.Local)
+	require.NoError(t, status.NewStore(stateDir).Update(history.Record{
+		Job:       "ran",
+		StartTime: lastSuccess.Add(-time.Minute),
+		EndTime:   lastSuccess,
+		Status:    history.StatusSuccess,
+	}))
+	require.NoError(t, status.NewStore(stateDir).Update(history.Record{
+		Job:       "failed",
+		StartTime: lastSuccess,
+		EndTime:   lastSuccess,
+		Status:    history.StatusFailure,
+	}))
+
+	for _, tc := range []struct {
+		name string
+		job  string
+		cfg  config.JobConfigV1
+		want time.Time
+	}{
+		{"never ran", "nope", config.JobConfigV1{Interval: time.Hour}, time.Time{}},
+		{"never succeeded", "failed", config.JobConfigV1{Interval: time.Hour}, time.Time{}},
+		{"no schedule or interval", "ran", config.JobConfigV1{}, time.Time{}},
+		{"interval", "ran", config.JobConfigV1{Interval: 6 * time.Hour}, lastSuccess.Add(6 * time.Hour)},
+		{
+			"schedule",
+			"ran",
+			config.JobConfigV1{Schedule: "0 3 * * *"},
+			time.Date(2025, 1, 16, 3, 0, 0, 0, time.Local),
+		},
+		{
+			"earliest of schedule and interval",
+			"ran",
+			config.JobConfigV1{Schedule: "0 3 * * *", Interval: time.Hour},
+			lastSuccess.Add(time.Hour),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := config.MainConfig{
+				StateDir: stateDir,
+				Jobs:     map[string]config.JobConfigV1{tc.job: tc.cfg},
+			}
+			res, err := NextDue(mc, tc.job)
+			require.NoError(t, err)
+			assert.True(t, tc.want.Equal(res), "expected %s, got %s", tc.want, res)
+		})
+	}
+}
+
+func TestNextDueUnknownJob(t *testing.T) {
+	_, err := NextDue(config.MainConfig{}, "nope")
+	assert.EqualError(t, err, "could not find a job named nope")
+}
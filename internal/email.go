@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
+)
+
+const emailBodyTemplate = `Backup job {{ .Job }} {{ if eq .Status "success" }}succeeded{{ else }}failed{{ end }}.
+
+Started:  {{ .StartTime.Format "2006-01-02 15:04:05 MST" }}
+Ended:    {{ .EndTime.Format "2006-01-02 15:04:05 MST" }}
+Duration: {{ .Duration }}
+{{- if .Destinations }}
+
+Destinations:
+{{- range .Destinations }}
+  - {{ .Destination }} ({{ .Backend }}): {{ .Status }}
+{{- if .Error }}
+    {{ .Error }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Errors }}
+
+Errors:
+{{- range .Errors }}
+  - {{ . }}
+{{- end }}
+{{- end }}
+{{- range .Outputs }}
+
+Output:
+{{ . }}
+{{- end }}
+`
+
+func sendEmail(ctx context.Context, email config.EmailV1, data NotificationData) error {
+	from, err := mail.ParseAddress(email.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address %s: %w", email.From, err)
+	}
+	to := make([]string, len(email.To))
+	for i, addr := range email.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid to address %s: %w", addr, err)
+		}
+		to[i] = parsed.Address
+	}
+	msg, err := renderEmail(email, data)
+	if err != nil {
+		return err
+	}
+	password := ""
+	if email.Username != "" {
+		password, err = renderTemplate("notify.email.password", email.Password, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, email.GetTimeout())
+	defer cancel()
+	err = doSendEmail(ctx, email, password, from.Address, to, msg)
+	if err == nil {
+		return nil
+	}
+	// SMTP servers answer with 4xx codes on temporary failures
+	var protoErr *textproto.Error
+	retryable := !errors.As(err, &protoErr) || (protoErr.Code >= 400 && protoErr.Code < 500)
+	return &sendError{err: process.Err(ctx, err), retryable: retryable}
+}
+
+func doSendEmail(
+	ctx context.Context,
+	email config.EmailV1,
+	password string,
+	from string,
+	to []string,
+	msg []byte,
+) error {
+	addr := net.JoinHostPort(email.Host, strconv.Itoa(email.GetPort()))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp doesn't know about contexts. Closing the connection interrupts
+	// whatever it's doing.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, email.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if email.UseStartTLS() {
+		err = c.StartTLS(&tls.Config{ServerName: email.Host})
+		if err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if email.Username != "" {
+		// PlainAuth refuses to send credentials over unencrypted connections
+		// to anything but localhost
+		err = c.Auth(smtp.PlainAuth("", email.Username, password, email.Host))
+		if err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	for _, addr := range to {
+		err = c.Rcpt(addr)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func renderEmail(email config.EmailV1, data NotificationData) ([]byte, error) {
+	subject, err := renderTemplate("notify.email.subject", email.GetSubject(), data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderTemplate("email body", emailBodyTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := bytes.Buffer{}
+	headers := [][2]string{
+		{"From", email.From},
+		{"To", strings.Join(email.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&msg)
+	_, err = qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	if err != nil {
+		return nil, err
+	}
+	err = qp.Close()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
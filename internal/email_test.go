@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedEmail struct {
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTPServer is just enough of an SMTP server for net/smtp to send emails
+// to it. It doesn't support TLS.
+type fakeSMTPServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	received []receivedEmail
+	// Codes to answer MAIL commands with, in order. Once it runs out of
+	// codes, it accepts everything.
+	mailCodes []int
+}
+
+func newFakeSMTPServer(t *testing.T, mailCodes ...int) *fakeSMTPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &fakeSMTPServer{listener: l, mailCodes: mailCodes}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) emails() []receivedEmail {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]receivedEmail{}, s.received...)
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) { _ = tp.PrintfLine("%d %s", code, msg) }
+	email := receivedEmail{}
+	reply(220, "localhost fake SMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			_ = tp.PrintfLine("250-localhost")
+			reply(250, "AUTH PLAIN")
+		case "AUTH":
+			_, creds, _ := strings.Cut(arg, " ")
+			raw, _ := base64.StdEncoding.DecodeString(creds)
+			email.Auth = string(raw)
+			reply(235, "ok")
+		case "MAIL":
+			s.lock.Lock()
+			code := 250
+			if len(s.mailCodes) > 0 {
+				code = s.mailCodes[0]
+				s.mailCodes = s.mailCodes[1:]
+			}
+			s.lock.Unlock()
+			email.From = strings.TrimSuffix(strings.TrimPrefix(arg, "FROM:<"), ">")
+			reply(code, "mail")
+		case "RCPT":
+			email.To = append(email.To, strings.TrimSuffix(strings.TrimPrefix(arg, "TO:<"), ">"))
+			reply(250, "ok")
+		case "DATA":
+			reply(354, "go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			email.Data = string(data)
+			s.lock.Lock()
+			s.received = append(s.received, email)
+			s.lock.Unlock()
+			reply(250, "queued")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+func decodeEmailBody(t *testing.T, data string) string {
+	t.Helper()
+	_, body, ok := strings.Cut(data, "\n\n")
+	require.True(t, ok, "email has no body: %s", data)
+	raw, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func testEmailConfig(srv *fakeSMTPServer) *config.EmailV1 {
+	startTLS := false
+	return &config.EmailV1{
+		Host:     "127.0.0.1",
+		Port:     srv.port(),
+		StartTLS: &startTLS,
+		Username: "backups",
+		Password: "{{ .Secrets.smtpPassword }}",
+		From:     "Backups <backups@example.com>",
+		To:       []string{"admin@example.com", "Other <other@example.com>"},
+	}
+}
+
+func TestBackupSendsEmail(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	svc := backupService{}
+	cfg := lockTestConfig(t.TempDir(), nil)
+	cfg.Secrets = map[string]string{"smtpPassword": "hunter2"}
+	cfg.MainConfig.StateDir = t.TempDir()
+	cfg.Recipes[0].Before = &config.HookV1{Shell: "bash", Command: "echo 'nope' >&2; exit 2"}
+	cfg.MainConfig.Jobs["my-job"] = config.JobConfigV1{
+		Recipe: "r",
+		OnFailure: &config.HookV1{
+			Shell:   "bash",
+			Command: "echo 'something went wrong'; exit 1",
+		},
+	}
+	cfg.MainConfig.Notify = &config.NotifyV1{Email: testEmailConfig(srv)}
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.Error(t, err)
+
+	emails := srv.emails()
+	require.Len(t, emails, 1)
+	assert.Equal(t, "\x00backups\x00hunter2", emails[0].Auth)
+	assert.Equal(t, "backups@example.com", emails[0].From)
+	assert.Equal(t, []string{"admin@example.com", "other@example.com"}, emails[0].To)
+	assert.Contains(t, emails[0].Data, "Subject: [standard-backups] my-job: failure\n")
+	assert.Contains(t, emails[0].Data, "To: admin@example.com, Other <other@example.com>\n")
+	body := decodeEmailBody(t, emails[0].Data)
+	assert.Contains(t, body, "Backup job my-job failed.\n")
+	assert.Contains(t, body, "\nErrors:\n  - before hook failed: exit status 2\n")
+	assert.Contains(t, body, "\nOutput:\nnope\n")
+	assert.Contains(t, body, "\nOutput:\nsomething went wrong\n")
+}
+
+func TestBackupEmailOnlyOnFailure(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	svc := backupService{}
+	cfg := lockTestConfig(t.TempDir(), nil)
+	cfg.MainConfig.StateDir = t.TempDir()
+	email := testEmailConfig(srv)
+	email.On = []string{config.NotifyOnFailure}
+	cfg.MainConfig.Notify = &config.NotifyV1{Email: email}
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+	assert.Empty(t, srv.emails())
+}
+
+func TestBackupEmailRetry(t *testing.T) {
+	srv := newFakeSMTPServer(t, 451, 554)
+	sleeps := []time.Duration{}
+	svc := backupService{sleep: func(d time.Duration) { sleeps = append(sleeps, d) }}
+	cfg := lockTestConfig(t.TempDir(), nil)
+	cfg.Secrets = map[string]string{"smtpPassword": "hunter2"}
+	cfg.MainConfig.StateDir = t.TempDir()
+	email := testEmailConfig(srv)
+	email.Retry = &config.RetryV1{Attempts: 5, InitialDelay: time.Second}
+	cfg.MainConfig.Notify = &config.NotifyV1{Email: email}
+
+	// The first attempt fails temporarily (451) and the second one fails for
+	// good (554) so there's no third attempt.
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second}, sleeps)
+	assert.Empty(t, srv.emails())
+}
+
+func TestSendEmailSubjectTemplate(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	email := testEmailConfig(srv)
+	email.Username = ""
+	email.Subject = "Backup of {{ .Job }} took {{ .Duration }}"
+	data := newNotificationData(
+		config.Config{},
+		&JobResult{
+			Job:       "my-job",
+			StartTime: time.Now().Add(-90 * time.Second),
+			EndTime:   time.Now(),
+			Destinations: []DestinationResult{{
+				Destination: "dest",
+				Backend:     "the-backend",
+				Err: process.WithOutput(errors.New("exit status 1"), func() *process.Tail {
+					tail := &process.Tail{}
+					_, _ = tail.Write([]byte("repository is locked\n"))
+					return tail
+				}()),
+			}},
+		},
+		errors.New("failed to backup destination named dest: exit status 1"),
+	)
+
+	require.NoError(t, sendEmail(context.Background(), *email, data))
+
+	emails := srv.emails()
+	require.Len(t, emails, 1)
+	assert.Empty(t, emails[0].Auth)
+	assert.Contains(t, emails[0].Data, "Subject: Backup of my-job took 1m30s\n")
+	body := decodeEmailBody(t, emails[0].Data)
+	assert.Contains(t, body,
+		"\nDestinations:\n  - dest (the-backend): failure\n    exit status 1\n")
+	assert.Equal(t, "repository is locked", data.Destinations[0].Output)
+}
+
+func TestSendEmailConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	err = sendEmail(context.Background(), config.EmailV1{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "backups@example.com",
+		To:   []string{"admin@example.com"},
+	}, NotificationData{})
+	var sendErr *sendError
+	if assert.ErrorAs(t, err, &sendErr) {
+		assert.True(t, sendErr.retryable)
+	}
+	assert.ErrorContains(t, err, strconv.Itoa(port))
+}
+
+func TestRenderEmailEncodesSubject(t *testing.T) {
+	msg, err := renderEmail(config.EmailV1{
+		From:    "backups@example.com",
+		To:      []string{"admin@example.com"},
+		Subject: "Sauvegarde réussie\nBcc: evil@example.com",
+	}, NotificationData{})
+	require.NoError(t, err)
+	headers, err := textproto.NewReader(bufio.NewReader(strings.NewReader(string(msg)))).
+		ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Empty(t, headers.Get("Bcc"))
+	assert.True(t, strings.HasPrefix(headers.Get("Subject"), "=?utf-8?q?"))
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"log/slog"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/internal/status"
+)
+
+// recordRun appends the outcome of a job to the run history and updates the
+// job's status. Both are informational so failing to write them doesn't fail
+// the backup.
+func recordRun(mc config.MainConfig, result *JobResult, err error) {
+	rec := newHistoryRecord(result, err)
+	store := history.NewStore(mc.GetStateDir())
+	appendErr := store.Append(rec)
+	if appendErr != nil {
+		slog.Warn("failed to record backup in history",
+			slog.String("path", store.Path()),
+			slog.Any("error", appendErr))
+	}
+	updateErr := status.NewStore(mc.GetStateDir()).Update(rec)
+	if updateErr != nil {
+		slog.Warn("failed to update job status",
+			slog.String("job", rec.Job),
+			slog.Any("error", updateErr))
+	}
+}
+
+func newHistoryRecord(result *JobResult, err error) history.Record {
+	rec := history.Record{
+		Job:          result.Job,
+		StartTime:    result.StartTime,
+		EndTime:      result.EndTime,
+		Status:       history.StatusSuccess,
+		Destinations: make([]history.DestinationRecord, len(result.Destinations)),
+	}
+	if result.Skipped {
+		rec.Status = history.StatusSkipped
+	} else if err != nil {
+		rec.Status = history.StatusFailure
+		rec.Error = redact.String(err.Error())
+	}
+	for i, dest := range result.Destinations {
+		destRec := history.DestinationRecord{
+			Destination: dest.Destination,
+			Backend:     dest.Backend,
+			StartTime:   dest.StartTime,
+			EndTime:     dest.EndTime,
+			Status:      history.StatusSuccess,
+			Result:      dest.Response,
+		}
+		if dest.Err != nil {
+			destRec.Status = history.StatusFailure
+			destRec.Error = redact.String(dest.Err.Error())
+		}
+		rec.Destinations[i] = destRec
+	}
+	return rec
+}
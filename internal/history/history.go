@@ -0,0 +1,147 @@
+// Package history records every backup job run in a local file so that users
+// can look back at what happened.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/dotboris/standard-backups/pkg/proto"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusSkipped = "skipped"
+)
+
+type (
+	// Record describes one run of a backup job.
+	Record struct {
+		Job          string              `json:"job"`
+		StartTime    time.Time           `json:"start_time"`
+		EndTime      time.Time           `json:"end_time"`
+		Status       string              `json:"status"`
+		Error        string              `json:"error,omitempty"`
+		Destinations []DestinationRecord `json:"destinations"`
+	}
+	// DestinationRecord describes the backup of a job to one destination.
+	DestinationRecord struct {
+		Destination string                `json:"destination"`
+		Backend     string                `json:"backend"`
+		StartTime   time.Time             `json:"start_time"`
+		EndTime     time.Time             `json:"end_time"`
+		Status      string                `json:"status"`
+		Error       string                `json:"error,omitempty"`
+		Result      *proto.BackupResponse `json:"result,omitempty"`
+	}
+	// Filter selects records. Zero values match everything.
+	Filter struct {
+		Job    string
+		Status string
+		// Only keep the most recent records
+		Limit int
+	}
+)
+
+// Store keeps records as JSON lines in a single file. Records are only ever
+// appended so that the file doubles as an audit log.
+type Store struct {
+	path string
+}
+
+// NewStore returns a store that keeps its records in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{path: path.Join(dir, "history.jsonl")}
+}
+
+// Path is the file where records are stored
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append adds a record at the end of the history.
+func (s *Store) Append(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	err = os.MkdirAll(path.Dir(s.path), 0o755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// A single write keeps records from getting interleaved when multiple jobs
+	// finish at the same time.
+	_, err = f.Write(line)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the records matching the filter from oldest to newest. A
+// missing history file means that nothing ran yet.
+func (s *Store) Read(filter Filter) ([]Record, error) {
+	res := []Record{}
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024) // backend results can carry a lot of extra data
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		var rec Record
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			// Don't let a single bad line (ex: power loss mid-write) hide the
+			// rest of the history
+			slog.Warn("skipping malformed history record",
+				slog.String("path", s.path),
+				slog.Int("line", lineNumber),
+				slog.Any("error", err))
+			continue
+		}
+		if filter.matches(rec) {
+			res = append(res, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history %s: %w", s.path, err)
+	}
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = slices.Clone(res[len(res)-filter.Limit:])
+	}
+	return res, nil
+}
+
+func (f Filter) matches(rec Record) bool {
+	if f.Job != "" && rec.Job != f.Job {
+		return false
+	}
+	if f.Status != "" && rec.Status != f.Status {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAppendRead(t *testing.T) {
+	store := NewStore(path.Join(t.TempDir(), "state"))
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []Record{
+		{
+			Job:       "a",
+			StartTime: start,
+			EndTime:   start.Add(time.Minute),
+			Status:    StatusSuccess,
+			Destinations: []DestinationRecord{{
+				Destination: "dest",
+				Backend:     "restic",
+				StartTime:   start,
+				EndTime:     start.Add(time.Minute),
+				Status:      StatusSuccess,
+				Result:      &proto.BackupResponse{Id: "abc123", BytesAdded: 42},
+			}},
+		},
+		{
+			Job:          "b",
+			StartTime:    start.Add(time.Hour),
+			EndTime:      start.Add(time.Hour),
+			Status:       StatusFailure,
+			Error:        "oops",
+			Destinations: []DestinationRecord{},
+		},
+		{
+			Job:          "a",
+			StartTime:    start.Add(2 * time.Hour),
+			EndTime:      start.Add(2 * time.Hour),
+			Status:       StatusSkipped,
+			Destinations: []DestinationRecord{},
+		},
+	}
+	for _, rec := range records {
+		require.NoError(t, store.Append(rec))
+	}
+
+	res, err := store.Read(Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, records, res)
+
+	res, err = store.Read(Filter{Job: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{records[0], records[2]}, res)
+
+	res, err = store.Read(Filter{Status: StatusFailure})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{records[1]}, res)
+
+	res, err = store.Read(Filter{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{records[1], records[2]}, res)
+}
+
+func TestStoreReadMissing(t *testing.T) {
+	store := NewStore(t.TempDir())
+	res, err := store.Read(Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
+
+func TestStoreReadSkipsMalformed(t *testing.T) {
+	store := NewStore(t.TempDir())
+	require.NoError(t, store.Append(Record{Job: "a", Status: StatusSuccess}))
+	f, err := os.OpenFile(store.Path(), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"job\": \"trunc\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, store.Append(Record{Job: "b", Status: StatusSuccess}))
+
+	res, err := store.Read(Filter{})
+	require.NoError(t, err)
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, "a", res[0].Job)
+		assert.Equal(t, "b", res[1].Job)
+	}
+}
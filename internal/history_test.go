@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/internal/status"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRecordsHistory(t *testing.T) {
+	require.NoError(t, redact.AddSecrets("history-secret"))
+	response := &proto.BackupResponse{Id: "abc123", BytesAdded: 42}
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "good-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(response, nil)
+			return client, nil
+		})
+	fac.EXPECT().NewBackendClient(mock.Anything, "bad-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, errors.New("bad password history-secret"))
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+	stateDir := t.TempDir()
+
+	res, _ := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				StateDir: stateDir,
+				Destinations: map[string]config.DestinationConfigV1{
+					"good": {Backend: "good-backend"},
+					"bad":  {Backend: "bad-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"good", "bad"}},
+				},
+			},
+		},
+		"my-job",
+	)
+
+	records, err := history.NewStore(stateDir).Read(history.Filter{})
+	require.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		rec := records[0]
+		assert.Equal(t, "my-job", rec.Job)
+		assert.Equal(t, history.StatusFailure, rec.Status)
+		assert.True(t, res.StartTime.Equal(rec.StartTime))
+		assert.True(t, res.EndTime.Equal(rec.EndTime))
+		assert.Equal(t, "failed to backup destination named bad: bad password ***", rec.Error)
+		if assert.Len(t, rec.Destinations, 2) {
+			assert.Equal(t, "good", rec.Destinations[0].Destination)
+			assert.Equal(t, "good-backend", rec.Destinations[0].Backend)
+			assert.Equal(t, history.StatusSuccess, rec.Destinations[0].Status)
+			assert.Equal(t, response, rec.Destinations[0].Result)
+			assert.Equal(t, "bad", rec.Destinations[1].Destination)
+			assert.Equal(t, history.StatusFailure, rec.Destinations[1].Status)
+			assert.Equal(t, "failed to backup destination named bad: bad password ***",
+				rec.Destinations[1].Error)
+			assert.Nil(t, rec.Destinations[1].Result)
+		}
+	}
+
+	js, err := status.NewStore(stateDir).Load("my-job")
+	require.NoError(t, err)
+	if assert.NotNil(t, js) {
+		assert.Equal(t, history.StatusFailure, js.LastRunStatus)
+		assert.Nil(t, js.LastSuccess)
+		assert.True(t, res.EndTime.Equal(*js.LastFailure))
+		assert.Equal(t, "failed to backup destination named bad: bad password ***", js.LastError)
+	}
+}
+
+func TestBackupRecordsSkippedHistory(t *testing.T) {
+	runtimeDir := t.TempDir()
+	stateDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+	cfg := lockTestConfig(runtimeDir, &config.LockV1{Policy: config.LockPolicySkip})
+	cfg.MainConfig.StateDir = stateDir
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+
+	records, err := history.NewStore(stateDir).Read(history.Filter{})
+	require.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, history.StatusSkipped, records[0].Status)
+	}
+}
+
+func TestBackupUnknownJobNotRecorded(t *testing.T) {
+	stateDir := t.TempDir()
+	svc := backupService{}
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{MainConfig: config.MainConfig{StateDir: stateDir}},
+		"nope",
+	)
+	assert.Error(t, err)
+	assert.NoFileExists(t, history.NewStore(stateDir).Path())
+}
@@ -1,33 +1,104 @@
 package internal
 
 import (
-	"errors"
-	"os/exec"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/internal/redact"
 )
 
-var errUnsupportedShell = errors.New("unsupported shell")
-
-func runHook(hook config.HookV1) error {
-	var (
-		command string
-		args    []string
-	)
-	switch hook.Shell {
-	case "sh":
-		command = "sh"
-		args = []string{"-c", hook.Command}
-	case "bash":
-		command = "bash"
-		args = []string{"-c", hook.Command}
-	default:
-		return errUnsupportedShell
+// Hook phases as reported to hooks through hookPhaseEnv
+const (
+	hookPhaseBefore    = "before"
+	hookPhaseAfter     = "after"
+	hookPhaseOnSuccess = "on-success"
+	hookPhaseOnFailure = "on-failure"
+)
+
+// Environment variables that tell hooks about the job they run for. Lists
+// have one item per line so that they're easy to loop over in shell scripts.
+const (
+	hookJobNameEnv             = "STANDARD_BACKUPS_JOB_NAME"
+	hookRecipeNameEnv          = "STANDARD_BACKUPS_RECIPE_NAME"
+	hookPhaseEnv               = "STANDARD_BACKUPS_HOOK"
+	hookDestinationsEnv        = "STANDARD_BACKUPS_DESTINATIONS"
+	hookDestinationStatusesEnv = "STANDARD_BACKUPS_DESTINATION_STATUSES"
+	hookErrorEnv               = "STANDARD_BACKUPS_ERROR"
+	hookDurationEnv            = "STANDARD_BACKUPS_DURATION"
+	hookPathsEnv               = "STANDARD_BACKUPS_PATHS"
+)
+
+// hookContext is what hooks get to know about the job they run for.
+type hookContext struct {
+	job    string
+	recipe config.RecipeManifestV1
+	// Destinations the job backs up to (ex: my-dest/daily)
+	destinations []string
+	startTime    time.Time
+	// Outcome of every destination. Empty until destinations are backed up.
+	results []DestinationResult
+	// Errors so far
+	err error
+}
+
+// env returns the environment variables that describe the job to a hook
+// running in the given phase. Only the before and after hooks get the recipe's
+// paths since they're the ones that prepare and clean them up.
+func (h hookContext) env(phase string, now time.Time) []string {
+	res := []string{
+		fmt.Sprintf("%s=%s", hookJobNameEnv, h.job),
+		fmt.Sprintf("%s=%s", hookRecipeNameEnv, h.recipe.Name),
+		fmt.Sprintf("%s=%s", hookPhaseEnv, phase),
+		fmt.Sprintf("%s=%s", hookDestinationsEnv, strings.Join(h.destinations, "\n")),
+		fmt.Sprintf("%s=%s", hookDurationEnv, strconv.Itoa(int(now.Sub(h.startTime).Seconds()))),
+	}
+	if len(h.results) > 0 {
+		statuses := make([]string, len(h.results))
+		for i, r := range h.results {
+			status := "success"
+			if r.Err != nil {
+				status = "failure"
+			}
+			statuses[i] = r.Destination + "=" + status
+		}
+		res = append(res, fmt.Sprintf("%s=%s", hookDestinationStatusesEnv, strings.Join(statuses, "\n")))
+	}
+	if h.err != nil {
+		res = append(res, fmt.Sprintf("%s=%s", hookErrorEnv, redact.String(h.err.Error())))
+	}
+	if phase == hookPhaseBefore || phase == hookPhaseAfter {
+		res = append(res, fmt.Sprintf("%s=%s", hookPathsEnv, strings.Join(h.recipe.Paths, "\n")))
+	}
+	return res
+}
+
+// runHook runs the given hook. env is added to the environment inherited from
+// standard-backups.
+func runHook(ctx context.Context, hook config.HookV1, env []string) error {
+	command, args, err := hook.ShellCommand()
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = redact.Stderr
-	cmd.Stderr = redact.Stderr
-	return cmd.Run()
+	ctx, cancel := process.WithTimeout(ctx, hook.Timeout)
+	defer cancel()
+	cmd := process.Command(ctx, command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	tail := &process.Tail{}
+	// Separate writers so that one stream can't break up a secret that the
+	// other is in the middle of writing.
+	stdout := redact.NewStderr()
+	defer func() { _ = stdout.Close() }()
+	stderr := redact.NewStderr()
+	defer func() { _ = stderr.Close() }()
+	cmd.Stdout = io.MultiWriter(stdout, tail)
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+	return process.WithOutput(process.Err(ctx, cmd.Run()), tail)
 }
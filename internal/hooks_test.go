@@ -1,13 +1,17 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/internal/testutils"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,12 +19,12 @@ import (
 func TestRunHookSh(t *testing.T) {
 	d := t.TempDir()
 	outFile := path.Join(d, "out.txt")
-	err := runHook(config.HookV1{
+	err := runHook(context.Background(), config.HookV1{
 		Shell: "sh",
 		Command: testutils.Dedent(fmt.Sprintf(`
 			echo hello from $0 > %s
 		`, outFile)),
-	})
+	}, nil)
 	if assert.NoError(t, err) {
 		content, err := os.ReadFile(outFile)
 		if assert.NoError(t, err) {
@@ -32,12 +36,12 @@ func TestRunHookSh(t *testing.T) {
 func TestRunHookBash(t *testing.T) {
 	d := t.TempDir()
 	outFile := path.Join(d, "out.txt")
-	err := runHook(config.HookV1{
+	err := runHook(context.Background(), config.HookV1{
 		Shell: "bash",
 		Command: testutils.Dedent(fmt.Sprintf(`
 			echo hello from $0 > %s
 		`, outFile)),
-	})
+	}, nil)
 	if assert.NoError(t, err) {
 		content, err := os.ReadFile(outFile)
 		if assert.NoError(t, err) {
@@ -47,20 +51,20 @@ func TestRunHookBash(t *testing.T) {
 }
 
 func TestRunHookUnsupportedShell(t *testing.T) {
-	err := runHook(config.HookV1{
+	err := runHook(context.Background(), config.HookV1{
 		Shell:   "bogus",
 		Command: "bogus",
-	})
-	assert.ErrorIs(t, err, errUnsupportedShell)
+	}, nil)
+	assert.ErrorIs(t, err, config.ErrUnsupportedShell)
 }
 
 func TestRunHookShError(t *testing.T) {
-	err := runHook(config.HookV1{
+	err := runHook(context.Background(), config.HookV1{
 		Shell: "sh",
 		Command: testutils.Dedent(`
 			exit 42
 		`),
-	})
+	}, nil)
 	var exitError *exec.ExitError
 	if assert.Error(t, err) && assert.ErrorAs(t, err, &exitError) {
 		assert.Equal(t, exitError.ExitCode(), 42)
@@ -68,14 +72,79 @@ func TestRunHookShError(t *testing.T) {
 }
 
 func TestRunHookBashError(t *testing.T) {
-	err := runHook(config.HookV1{
+	err := runHook(context.Background(), config.HookV1{
 		Shell: "sh",
 		Command: testutils.Dedent(`
 			exit 42
 		`),
-	})
+	}, nil)
 	var exitError *exec.ExitError
 	if assert.Error(t, err) && assert.ErrorAs(t, err, &exitError) {
 		assert.Equal(t, exitError.ExitCode(), 42)
 	}
 }
+
+func TestRunHookTimeout(t *testing.T) {
+	start := time.Now()
+	err := runHook(context.Background(), config.HookV1{
+		Shell:   "sh",
+		Command: "sleep 60",
+		Timeout: 50 * time.Millisecond,
+	}, nil)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	var timeoutErr *process.TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	}
+}
+
+func TestRunHookEnv(t *testing.T) {
+	d := t.TempDir()
+	outFile := path.Join(d, "out.txt")
+	err := runHook(context.Background(), config.HookV1{
+		Shell:   "bash",
+		Command: fmt.Sprintf(`echo "$FOO" > %s`, outFile),
+	}, []string{"FOO=bar"})
+	if assert.NoError(t, err) {
+		content, err := os.ReadFile(outFile)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "bar\n", string(content))
+		}
+	}
+}
+
+func TestHookContextEnv(t *testing.T) {
+	start := time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)
+	hc := hookContext{
+		job:          "my-job",
+		recipe:       config.RecipeManifestV1{Name: "r", Paths: []string{"/a", "/b c"}},
+		destinations: []string{"d1/weekly", "d1", "d2"},
+		startTime:    start,
+	}
+	assert.Equal(t, []string{
+		"STANDARD_BACKUPS_JOB_NAME=my-job",
+		"STANDARD_BACKUPS_RECIPE_NAME=r",
+		"STANDARD_BACKUPS_HOOK=before",
+		"STANDARD_BACKUPS_DESTINATIONS=d1/weekly\nd1\nd2",
+		"STANDARD_BACKUPS_DURATION=0",
+		"STANDARD_BACKUPS_PATHS=/a\n/b c",
+	}, hc.env(hookPhaseBefore, start))
+
+	// Like backupDestination, results hold the destinations as listed in
+	// backup-to along with the variant they resolved to.
+	hc.results = []DestinationResult{
+		{Destination: "d1/weekly", Variant: "weekly"},
+		{Destination: "d1", Variant: "daily"},
+		{Destination: "d2", Err: errors.New("oops")},
+	}
+	hc.err = errors.New("oops")
+	assert.Equal(t, []string{
+		"STANDARD_BACKUPS_JOB_NAME=my-job",
+		"STANDARD_BACKUPS_RECIPE_NAME=r",
+		"STANDARD_BACKUPS_HOOK=on-failure",
+		"STANDARD_BACKUPS_DESTINATIONS=d1/weekly\nd1\nd2",
+		"STANDARD_BACKUPS_DURATION=90",
+		"STANDARD_BACKUPS_DESTINATION_STATUSES=d1/weekly=success\nd1=success\nd2=failure",
+		"STANDARD_BACKUPS_ERROR=oops",
+	}, hc.env(hookPhaseOnFailure, start.Add(90*time.Second)))
+}
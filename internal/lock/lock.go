@@ -0,0 +1,137 @@
+// Package lock implements exclusive locks backed by files. They are used to
+// prevent multiple instances of standard-backups from running the same job at
+// the same time.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often Wait retries acquiring a lock held by another
+// process.
+var pollInterval = time.Second
+
+// LockedError is returned when a lock is held by another process.
+type LockedError struct {
+	Path string
+	// PID of the process holding the lock. 0 when unknown.
+	Pid int
+}
+
+func (e *LockedError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by process %d", e.Path, e.Pid)
+}
+
+// Lock is an exclusive lock on a file. The lock file contains the PID of the
+// process holding it.
+type Lock struct {
+	file *os.File
+}
+
+// TryAcquire acquires the lock at the given path without waiting. It returns a
+// *LockedError when another process holds the lock.
+//
+// The lock is held with flock so the kernel releases it when the process
+// holding it dies. The lock files left behind by such processes still contain
+// their PID. They are detected as stale and taken over.
+func TryAcquire(p string) (*Lock, error) {
+	err := os.MkdirAll(path.Dir(p), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		pid, _ := readPid(f)
+		f.Close()
+		return nil, &LockedError{Path: p, Pid: pid}
+	} else if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", p, err)
+	}
+
+	// The previous holder released the lock without emptying the file. It must
+	// have died before it could clean up.
+	pid, err := readPid(f)
+	if err == nil && pid != 0 {
+		slog.Warn("taking over stale lock left by dead process",
+			slog.String("path", p),
+			slog.Int("pid", pid))
+	}
+	err = writePid(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write pid to lock file %s: %w", p, err)
+	}
+	return &Lock{file: f}, nil
+}
+
+// Wait acquires the lock at the given path. If another process holds it, it
+// waits until the lock is released or until ctx is done.
+func Wait(ctx context.Context, p string) (*Lock, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		l, err := TryAcquire(p)
+		var lockedErr *LockedError
+		if !errors.As(err, &lockedErr) {
+			return l, err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", err, context.Cause(ctx))
+		}
+	}
+}
+
+// Release releases the lock. The lock file is emptied, not removed. Removing
+// it would race with other processes that opened it but have yet to lock it.
+func (l *Lock) Release() error {
+	err := l.file.Truncate(0)
+	if err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func readPid(f *os.File) (int, error) {
+	buf := make([]byte, 32)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && n == 0 {
+		return 0, nil
+	}
+	raw := strings.TrimSpace(string(buf[:n]))
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(raw)
+}
+
+func writePid(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
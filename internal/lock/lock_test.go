@@ -0,0 +1,88 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryAcquire(t *testing.T) {
+	p := path.Join(t.TempDir(), "locks", "my-job.lock")
+	l, err := TryAcquire(p)
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(fmt.Sprintf("%d\n", os.Getpid())), raw)
+
+	_, err = TryAcquire(p)
+	var lockedErr *LockedError
+	if assert.ErrorAs(t, err, &lockedErr) {
+		assert.Equal(t, os.Getpid(), lockedErr.Pid)
+		assert.Equal(t, p, lockedErr.Path)
+	}
+
+	require.NoError(t, l.Release())
+	raw, err = os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Empty(t, raw)
+
+	l, err = TryAcquire(p)
+	require.NoError(t, err)
+	require.NoError(t, l.Release())
+}
+
+func TestTryAcquireStale(t *testing.T) {
+	p := path.Join(t.TempDir(), "my-job.lock")
+	// Left behind by a process that died while holding the lock
+	require.NoError(t, os.WriteFile(p, []byte("999999999\n"), 0o644))
+
+	l, err := TryAcquire(p)
+	require.NoError(t, err)
+	defer l.Release()
+	raw, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(fmt.Sprintf("%d\n", os.Getpid())), raw)
+}
+
+func TestWait(t *testing.T) {
+	original := pollInterval
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pollInterval = original })
+
+	p := path.Join(t.TempDir(), "my-job.lock")
+	l, err := TryAcquire(p)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l.Release()
+	}()
+
+	l2, err := Wait(context.Background(), p)
+	require.NoError(t, err)
+	require.NoError(t, l2.Release())
+}
+
+func TestWaitCanceled(t *testing.T) {
+	original := pollInterval
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pollInterval = original })
+
+	p := path.Join(t.TempDir(), "my-job.lock")
+	l, err := TryAcquire(p)
+	require.NoError(t, err)
+	defer l.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = Wait(ctx, p)
+	var lockedErr *LockedError
+	assert.ErrorAs(t, err, &lockedErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
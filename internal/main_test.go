@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Keep job locks and history out of the host's directories
+	tmpDir, err := os.MkdirTemp("", "standard-backups-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_RUNTIME_DIR", path.Join(tmpDir, "runtime"))
+	os.Setenv("XDG_STATE_HOME", path.Join(tmpDir, "state"))
+	code := m.Run()
+	os.RemoveAll(tmpDir)
+	os.Exit(code)
+}
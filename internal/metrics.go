@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/metrics"
+)
+
+// exportMetrics writes the outcome of a job to a .prom file in the metrics
+// textfile directory. Like the history, metrics are informational so failing
+// to write them doesn't fail the backup.
+func exportMetrics(mc config.MainConfig, result *JobResult, err error) {
+	if mc.Metrics == nil || mc.Metrics.TextfileDir == "" {
+		return
+	}
+	// The previous run's metrics are still accurate
+	if result.Skipped {
+		return
+	}
+	p := path.Join(mc.Metrics.TextfileDir, "standard-backups-"+result.Job+".prom")
+	writeErr := metrics.WriteTextfile(p, newJobGauges(result, err))
+	if writeErr != nil {
+		slog.Warn("failed to write metrics",
+			slog.String("path", p),
+			slog.Any("error", writeErr))
+	}
+}
+
+func newJobGauges(result *JobResult, err error) []metrics.Gauge {
+	jobLabels := []metrics.Label{{Name: "job", Value: result.Job}}
+	gauges := []metrics.Gauge{
+		{
+			Name:    "standard_backups_job_last_start_timestamp_seconds",
+			Help:    "Time when the last run of the job started.",
+			Samples: []metrics.Sample{{Labels: jobLabels, Value: timestamp(result.StartTime)}},
+		},
+		{
+			Name:    "standard_backups_job_last_end_timestamp_seconds",
+			Help:    "Time when the last run of the job ended.",
+			Samples: []metrics.Sample{{Labels: jobLabels, Value: timestamp(result.EndTime)}},
+		},
+		{
+			Name: "standard_backups_job_last_duration_seconds",
+			Help: "How long the last run of the job took.",
+			Samples: []metrics.Sample{{
+				Labels: jobLabels,
+				Value:  result.EndTime.Sub(result.StartTime).Seconds(),
+			}},
+		},
+		{
+			Name:    "standard_backups_job_last_success",
+			Help:    "1 if the last run of the job succeeded, 0 otherwise.",
+			Samples: []metrics.Sample{{Labels: jobLabels, Value: boolValue(err == nil)}},
+		},
+	}
+
+	destGauges := []*metrics.Gauge{}
+	destGauge := func(name string, help string) *metrics.Gauge {
+		g := &metrics.Gauge{Name: "standard_backups_destination_" + name, Help: help}
+		destGauges = append(destGauges, g)
+		return g
+	}
+	startTime := destGauge("last_start_timestamp_seconds",
+		"Time when the last backup to the destination started.")
+	endTime := destGauge("last_end_timestamp_seconds",
+		"Time when the last backup to the destination ended.")
+	duration := destGauge("last_duration_seconds",
+		"How long the last backup to the destination took.")
+	success := destGauge("last_success",
+		"1 if the last backup to the destination succeeded, 0 otherwise.")
+	bytesAdded := destGauge("last_bytes_added",
+		"New data written to the destination by the last backup, as reported by the backend.")
+	bytesProcessed := destGauge("last_bytes_processed",
+		"Size of everything backed up by the last backup, as reported by the backend.")
+	filesNew := destGauge("last_files_new",
+		"New files in the last backup, as reported by the backend.")
+	filesChanged := destGauge("last_files_changed",
+		"Changed files in the last backup, as reported by the backend.")
+	filesUnmodified := destGauge("last_files_unmodified",
+		"Unmodified files in the last backup, as reported by the backend.")
+	filesProcessed := destGauge("last_files_processed",
+		"Files processed by the last backup, as reported by the backend.")
+
+	for _, dest := range result.Destinations {
+		destName, _, _ := strings.Cut(dest.Destination, "/")
+		labels := []metrics.Label{
+			{Name: "job", Value: result.Job},
+			{Name: "destination", Value: destName},
+			{Name: "variant", Value: dest.Variant},
+			{Name: "backend", Value: dest.Backend},
+		}
+		add := func(g *metrics.Gauge, value float64) {
+			g.Samples = append(g.Samples, metrics.Sample{Labels: labels, Value: value})
+		}
+		add(startTime, timestamp(dest.StartTime))
+		add(endTime, timestamp(dest.EndTime))
+		add(duration, dest.EndTime.Sub(dest.StartTime).Seconds())
+		add(success, boolValue(dest.Err == nil))
+		if res := dest.Response; res != nil {
+			add(bytesAdded, float64(res.BytesAdded))
+			add(bytesProcessed, float64(res.BytesProcessed))
+			add(filesNew, float64(res.FilesNew))
+			add(filesChanged, float64(res.FilesChanged))
+			add(filesUnmodified, float64(res.FilesUnmodified))
+			add(filesProcessed, float64(res.FilesProcessed))
+		}
+	}
+	for _, g := range destGauges {
+		gauges = append(gauges, *g)
+	}
+	return gauges
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+// Package metrics writes metrics in the Prometheus text exposition format so
+// that they can be picked up by node_exporter's textfile collector.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dotboris/standard-backups/internal/atomicfile"
+)
+
+type (
+	// Gauge is a metric family. Every sample must have the same label names.
+	Gauge struct {
+		Name    string
+		Help    string
+		Samples []Sample
+	}
+	Sample struct {
+		Labels []Label
+		Value  float64
+	}
+	Label struct {
+		Name  string
+		Value string
+	}
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// Write writes the given gauges in the Prometheus text exposition format.
+// Gauges without samples are left out.
+func Write(w io.Writer, gauges []Gauge) error {
+	bw := bufio.NewWriter(w)
+	for _, g := range gauges {
+		if len(g.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", g.Name, helpEscaper.Replace(g.Help))
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", g.Name)
+		for _, s := range g.Samples {
+			bw.WriteString(g.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, `%s="%s"`, l.Name, labelValueEscaper.Replace(l.Value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteTextfile replaces the given .prom file with the given gauges. The file
+// is replaced atomically so that node_exporter never reads a partially written
+// file.
+func WriteTextfile(p string, gauges []Gauge) error {
+	// The textfile collector only reads files ending in .prom so it ignores
+	// the temporary file until it's renamed.
+	return atomicfile.Write(p, 0o644, func(w io.Writer) error {
+		return Write(w, gauges)
+	})
+}
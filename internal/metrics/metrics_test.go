@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, []Gauge{
+		{
+			Name: "first",
+			Help: "Some help\nwith a \\ in it.",
+			Samples: []Sample{
+				{Labels: []Label{{"job", "a"}, {"destination", "b"}}, Value: 1},
+				{Labels: []Label{{"job", "say \"hi\"\n"}, {"destination", `c:\`}}, Value: 1.5},
+			},
+		},
+		{Name: "empty", Help: "Left out."},
+		{Name: "no_labels", Help: "Big.", Samples: []Sample{{Value: 1234567890.123}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP first Some help\nwith a \\ in it.
+# TYPE first gauge
+first{job="a",destination="b"} 1
+first{job="say \"hi\"\n",destination="c:\\"} 1.5
+# HELP no_labels Big.
+# TYPE no_labels gauge
+no_labels 1.234567890123e+09
+`, buf.String())
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := path.Join(t.TempDir(), "textfile")
+	p := path.Join(dir, "test.prom")
+	require.NoError(t, WriteTextfile(p, []Gauge{
+		{Name: "a", Help: "A.", Samples: []Sample{{Value: 1}}},
+	}))
+	require.NoError(t, WriteTextfile(p, []Gauge{
+		{Name: "a", Help: "A.", Samples: []Sample{{Value: 2}}},
+	}))
+
+	raw, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "# HELP a A.\n# TYPE a gauge\na 2\n", string(raw))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "test.prom", entries[0].Name())
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupExportsMetrics(t *testing.T) {
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "good-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(&proto.BackupResponse{BytesAdded: 42, FilesNew: 3}, nil)
+			return client, nil
+		})
+	fac.EXPECT().NewBackendClient(mock.Anything, "bad-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(nil, errors.New("oops"))
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+	textfileDir := t.TempDir()
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			MainConfig: config.MainConfig{
+				StateDir: t.TempDir(),
+				Metrics:  &config.MetricsV1{TextfileDir: textfileDir},
+				Destinations: map[string]config.DestinationConfigV1{
+					"good": {
+						Backend:  "good-backend",
+						Variants: map[string]map[string]any{"daily": {}},
+					},
+					"bad": {Backend: "bad-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"good/daily", "bad"}},
+				},
+			},
+		},
+		"my-job",
+	)
+	require.Error(t, err)
+
+	raw, err := os.ReadFile(path.Join(textfileDir, "standard-backups-my-job.prom"))
+	require.NoError(t, err)
+	prom := string(raw)
+	goodLabels := `{job="my-job",destination="good",variant="daily",backend="good-backend"}`
+	badLabels := `{job="my-job",destination="bad",variant="",backend="bad-backend"}`
+	assert.Contains(t, prom, "# TYPE standard_backups_job_last_success gauge\n")
+	assert.Contains(t, prom, "\nstandard_backups_job_last_success{job=\"my-job\"} 0\n")
+	assert.Regexp(t, `\nstandard_backups_job_last_start_timestamp_seconds\{job="my-job"\} \d`, prom)
+	assert.Regexp(t, `\nstandard_backups_job_last_end_timestamp_seconds\{job="my-job"\} \d`, prom)
+	assert.Regexp(t, `\nstandard_backups_job_last_duration_seconds\{job="my-job"\} \d`, prom)
+	assert.Contains(t, prom, "\nstandard_backups_destination_last_success"+goodLabels+" 1\n")
+	assert.Contains(t, prom, "\nstandard_backups_destination_last_success"+badLabels+" 0\n")
+	assert.Contains(t, prom, "\nstandard_backups_destination_last_bytes_added"+goodLabels+" 42\n")
+	assert.Contains(t, prom, "\nstandard_backups_destination_last_files_new"+goodLabels+" 3\n")
+	// The failed backup has no response to report on
+	assert.NotContains(t, prom, "standard_backups_destination_last_bytes_added"+badLabels)
+}
+
+func TestBackupSkippedKeepsMetrics(t *testing.T) {
+	runtimeDir := t.TempDir()
+	textfileDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+	cfg := lockTestConfig(runtimeDir, &config.LockV1{Policy: config.LockPolicySkip})
+	cfg.MainConfig.StateDir = t.TempDir()
+	cfg.MainConfig.Metrics = &config.MetricsV1{TextfileDir: textfileDir}
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(textfileDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
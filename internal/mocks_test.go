@@ -5,6 +5,8 @@
 package internal
 
 import (
+	"context"
+
 	"github.com/dotboris/standard-backups/internal/config"
 	"github.com/dotboris/standard-backups/pkg/proto"
 	mock "github.com/stretchr/testify/mock"
@@ -38,20 +40,31 @@ func (_m *mockBackuper) EXPECT() *mockBackuper_Expecter {
 }
 
 // Backup provides a mock function for the type mockBackuper
-func (_mock *mockBackuper) Backup(req *proto.BackupRequest) error {
-	ret := _mock.Called(req)
+func (_mock *mockBackuper) Backup(ctx context.Context, req *proto.BackupRequest, onProgress proto.ProgressFunc) (*proto.BackupResponse, error) {
+	ret := _mock.Called(ctx, req, onProgress)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Backup")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(*proto.BackupRequest) error); ok {
-		r0 = returnFunc(req)
+	var r0 *proto.BackupResponse
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *proto.BackupRequest, proto.ProgressFunc) (*proto.BackupResponse, error)); ok {
+		return returnFunc(ctx, req, onProgress)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *proto.BackupRequest, proto.ProgressFunc) *proto.BackupResponse); ok {
+		r0 = returnFunc(ctx, req, onProgress)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*proto.BackupResponse)
+		}
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *proto.BackupRequest, proto.ProgressFunc) error); ok {
+		r1 = returnFunc(ctx, req, onProgress)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
 // mockBackuper_Backup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Backup'
@@ -60,30 +73,42 @@ type mockBackuper_Backup_Call struct {
 }
 
 // Backup is a helper method to define mock.On call
+//   - ctx context.Context
 //   - req *proto.BackupRequest
-func (_e *mockBackuper_Expecter) Backup(req interface{}) *mockBackuper_Backup_Call {
-	return &mockBackuper_Backup_Call{Call: _e.mock.On("Backup", req)}
+//   - onProgress proto.ProgressFunc
+func (_e *mockBackuper_Expecter) Backup(ctx interface{}, req interface{}, onProgress interface{}) *mockBackuper_Backup_Call {
+	return &mockBackuper_Backup_Call{Call: _e.mock.On("Backup", ctx, req, onProgress)}
 }
 
-func (_c *mockBackuper_Backup_Call) Run(run func(req *proto.BackupRequest)) *mockBackuper_Backup_Call {
+func (_c *mockBackuper_Backup_Call) Run(run func(ctx context.Context, req *proto.BackupRequest, onProgress proto.ProgressFunc)) *mockBackuper_Backup_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 *proto.BackupRequest
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(*proto.BackupRequest)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *proto.BackupRequest
+		if args[1] != nil {
+			arg1 = args[1].(*proto.BackupRequest)
+		}
+		var arg2 proto.ProgressFunc
+		if args[2] != nil {
+			arg2 = args[2].(proto.ProgressFunc)
 		}
 		run(
 			arg0,
+			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *mockBackuper_Backup_Call) Return(err error) *mockBackuper_Backup_Call {
-	_c.Call.Return(err)
+func (_c *mockBackuper_Backup_Call) Return(backupResponse *proto.BackupResponse, err error) *mockBackuper_Backup_Call {
+	_c.Call.Return(backupResponse, err)
 	return _c
 }
 
-func (_c *mockBackuper_Backup_Call) RunAndReturn(run func(req *proto.BackupRequest) error) *mockBackuper_Backup_Call {
+func (_c *mockBackuper_Backup_Call) RunAndReturn(run func(ctx context.Context, req *proto.BackupRequest, onProgress proto.ProgressFunc) (*proto.BackupResponse, error)) *mockBackuper_Backup_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
+	"github.com/dotboris/standard-backups/internal/redact"
+	"github.com/dotboris/standard-backups/pkg/proto"
+)
+
+type (
+	// NotificationData is what notification templates have access to. It's
+	// also the default body of webhooks, minus the secrets.
+	NotificationData struct {
+		Job             string    `json:"job"`
+		Status          string    `json:"status"`
+		StartTime       time.Time `json:"start_time"`
+		EndTime         time.Time `json:"end_time"`
+		Duration        string    `json:"duration"`
+		DurationSeconds float64   `json:"duration_seconds"`
+		Errors          []string  `json:"errors"`
+		// Output of the hooks and backends that failed
+		Outputs      []string                  `json:"outputs"`
+		Destinations []NotificationDestination `json:"destinations"`
+		Secrets      map[string]string         `json:"-"`
+	}
+	NotificationDestination struct {
+		Destination     string                `json:"destination"`
+		Backend         string                `json:"backend"`
+		Status          string                `json:"status"`
+		Error           string                `json:"error,omitempty"`
+		Output          string                `json:"output,omitempty"`
+		DurationSeconds float64               `json:"duration_seconds"`
+		Result          *proto.BackupResponse `json:"result,omitempty"`
+	}
+)
+
+// sendError is returned when sending a notification fails. Retryable tells if
+// sending it again could succeed.
+type sendError struct {
+	err       error
+	retryable bool
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// notifier sends one of the notifications configured for a job.
+type notifier struct {
+	// Identifies the notification in logs
+	attr  slog.Attr
+	retry config.RetryV1
+	send  func(ctx context.Context, data NotificationData) error
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value as JSON. Use it to safely put values in JSON
+	// bodies (ex: {"text": {{ json .Job }}}).
+	"json": func(v any) (string, error) {
+		buf := bytes.Buffer{}
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n"), err
+	},
+}
+
+// notify sends the notifications configured for the job. Like the hooks that
+// run when a job ends, notifications get a limited amount of time once the
+// backup gets canceled. Failing to send a notification doesn't fail the
+// backup.
+func (s *backupService) notify(ctx context.Context, cfg config.Config, result *JobResult, err error) {
+	notify := cfg.MainConfig.Notify
+	if notify == nil || result.Skipped {
+		return
+	}
+	notifiers := []notifier{}
+	if email := notify.Email; email != nil && email.Matches(result.Job, err == nil) {
+		notifiers = append(notifiers, notifier{
+			attr:  slog.String("notification", "email"),
+			retry: email.GetRetry(),
+			send: func(ctx context.Context, data NotificationData) error {
+				return sendEmail(ctx, *email, data)
+			},
+		})
+	}
+	webhookNames := []string{}
+	for name, wh := range notify.Webhooks {
+		if wh.Matches(result.Job, err == nil) {
+			webhookNames = append(webhookNames, name)
+		}
+	}
+	sort.Strings(webhookNames)
+	for _, name := range webhookNames {
+		wh := notify.Webhooks[name]
+		notifiers = append(notifiers, notifier{
+			attr:  slog.String("webhook", name),
+			retry: wh.GetRetry(),
+			send: func(ctx context.Context, data NotificationData) error {
+				return sendWebhook(ctx, name, wh, data)
+			},
+		})
+	}
+	if len(notifiers) == 0 {
+		return
+	}
+
+	cleanupTimeout := s.cleanupTimeout
+	if cleanupTimeout == 0 {
+		cleanupTimeout = defaultCleanupTimeout
+	}
+	ctx, cancel := process.WithCleanupTimeout(ctx, cleanupTimeout)
+	defer cancel()
+
+	data := newNotificationData(cfg, result, err)
+	logger := slog.With(slog.String("job", result.Job))
+	for _, n := range notifiers {
+		err := s.sendWithRetry(ctx, logger, n, data)
+		if err != nil {
+			logger.Warn("failed to send notification", n.attr, slog.Any("error", err))
+		} else {
+			logger.Info("sent notification", n.attr)
+		}
+	}
+}
+
+func newNotificationData(cfg config.Config, result *JobResult, err error) NotificationData {
+	rec := newHistoryRecord(result, err)
+	duration := rec.EndTime.Sub(rec.StartTime)
+	data := NotificationData{
+		Job:             rec.Job,
+		Status:          rec.Status,
+		StartTime:       rec.StartTime,
+		EndTime:         rec.EndTime,
+		Duration:        duration.Round(time.Second).String(),
+		DurationSeconds: duration.Seconds(),
+		Errors:          []string{},
+		Outputs:         []string{},
+		Destinations:    make([]NotificationDestination, len(rec.Destinations)),
+		Secrets:         cfg.Secrets,
+	}
+	// Multiple things can fail at once (ex: a destination and the after hook).
+	// Report them separately.
+	for _, err := range splitErrors(err) {
+		data.Errors = append(data.Errors, redact.String(err.Error()))
+	}
+	for _, output := range process.Outputs(err) {
+		data.Outputs = append(data.Outputs, redact.String(output))
+	}
+	for i, dest := range rec.Destinations {
+		data.Destinations[i] = NotificationDestination{
+			Destination:     dest.Destination,
+			Backend:         dest.Backend,
+			Status:          dest.Status,
+			Error:           dest.Error,
+			Output:          redact.String(strings.Join(process.Outputs(result.Destinations[i].Err), "\n")),
+			DurationSeconds: dest.EndTime.Sub(dest.StartTime).Seconds(),
+			Result:          dest.Result,
+		}
+	}
+	return data
+}
+
+// splitErrors returns the errors that were joined together with errors.Join.
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	res := []error{}
+	for _, err := range joined.Unwrap() {
+		res = append(res, splitErrors(err)...)
+	}
+	return res
+}
+
+func (s *backupService) sendWithRetry(
+	ctx context.Context,
+	logger *slog.Logger,
+	n notifier,
+	data NotificationData,
+) error {
+	for attempt := 1; ; attempt++ {
+		err := n.send(ctx, data)
+		if err == nil {
+			return nil
+		}
+		var sendErr *sendError
+		retryable := errors.As(err, &sendErr) && sendErr.retryable
+		if !retryable || attempt >= n.retry.Attempts || ctx.Err() != nil {
+			if attempt > 1 {
+				err = fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		delay := retryDelay(n.retry, attempt, rand.Float64())
+		logger.Warn("notification attempt failed, retrying",
+			n.attr,
+			slog.Int("attempt", attempt),
+			slog.Int("attempts", n.retry.Attempts),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		s.doSleep(ctx, delay)
+	}
+}
+
+// renderTemplate renders a template from the notification settings. The name
+// tells where the template comes from in error messages.
+func renderTemplate(name string, text string, data NotificationData) (string, error) {
+	tpl, err := template.New(name).
+		Funcs(templateFuncs).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return "", err
+	}
+	out := bytes.Buffer{}
+	err = tpl.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func sendWebhook(ctx context.Context, name string, wh config.WebhookV1, data NotificationData) error {
+	req, err := renderWebhook(name, wh, data)
+	if err != nil {
+		return err
+	}
+	return doWebhookRequest(ctx, wh, req)
+}
+
+type webhookRequest struct {
+	url     string
+	headers http.Header
+	body    string
+}
+
+func renderWebhook(name string, wh config.WebhookV1, data NotificationData) (*webhookRequest, error) {
+	render := func(field string, text string) (string, error) {
+		return renderTemplate(fmt.Sprintf("notify.webhooks.%s.%s", name, field), text, data)
+	}
+
+	res := &webhookRequest{headers: http.Header{}}
+	var err error
+	res.url, err = render("url", wh.URL)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range wh.Headers {
+		value, err := render("headers."+key, value)
+		if err != nil {
+			return nil, err
+		}
+		res.headers.Set(key, value)
+	}
+	if wh.Body == "" {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		res.body = string(raw)
+		if res.headers.Get("Content-Type") == "" {
+			res.headers.Set("Content-Type", "application/json")
+		}
+	} else {
+		res.body, err = render("body", wh.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func doWebhookRequest(ctx context.Context, wh config.WebhookV1, req *webhookRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, wh.GetTimeout())
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, wh.GetMethod(), req.url, strings.NewReader(req.body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header = req.headers.Clone()
+	res, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return &sendError{err: err, retryable: true}
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("unexpected response %s", res.Status)
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+	return &sendError{
+		err:       err,
+		retryable: res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500,
+	}
+}
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedWebhook struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// newWebhookReceiver starts an HTTP server that records the requests it gets
+// and answers with the given status codes in order. Once it runs out of
+// status codes, it answers with 200.
+func newWebhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedWebhook) {
+	t.Helper()
+	lock := sync.Mutex{}
+	received := []receivedWebhook{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, receivedWebhook{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header,
+			Body:   string(body),
+		})
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status = statuses[0]
+			statuses = statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []receivedWebhook {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]receivedWebhook{}, received...)
+	}
+}
+
+func TestBackupSendsWebhook(t *testing.T) {
+	srv, received := newWebhookReceiver(t)
+	fac := newMockNewBackendClienter(t)
+	fac.EXPECT().NewBackendClient(mock.Anything, "the-backend").
+		RunAndReturn(func(c config.Config, s string) (backuper, error) {
+			client := newMockBackuper(t)
+			client.EXPECT().
+				Backup(mock.Anything, mock.Anything, mock.Anything).
+				Return(&proto.BackupResponse{Id: "abc123", BytesAdded: 42}, nil)
+			return client, nil
+		})
+	svc := backupService{backendClientFactory: fac}
+
+	_, err := svc.Backup(
+		context.Background(),
+		config.Config{
+			Recipes: []config.RecipeManifestV1{{Name: "r"}},
+			Secrets: map[string]string{"token": "sekret"},
+			MainConfig: config.MainConfig{
+				StateDir: t.TempDir(),
+				Destinations: map[string]config.DestinationConfigV1{
+					"dest": {Backend: "the-backend"},
+				},
+				Jobs: map[string]config.JobConfigV1{
+					"my-job": {Recipe: "r", BackupTo: []string{"dest"}},
+				},
+				Notify: &config.NotifyV1{
+					Webhooks: map[string]config.WebhookV1{
+						"hook": {
+							URL:     srv.URL + "/{{ .Job }}",
+							Headers: map[string]string{"Authorization": "Bearer {{ .Secrets.token }}"},
+						},
+					},
+				},
+			},
+		},
+		"my-job",
+	)
+	require.NoError(t, err)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "POST", reqs[0].Method)
+	assert.Equal(t, "/my-job", reqs[0].Path)
+	assert.Equal(t, "Bearer sekret", reqs[0].Header.Get("Authorization"))
+	assert.Equal(t, "application/json", reqs[0].Header.Get("Content-Type"))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal([]byte(reqs[0].Body), &body))
+	assert.Equal(t, "my-job", body["job"])
+	assert.Equal(t, "success", body["status"])
+	assert.Equal(t, []any{}, body["errors"])
+	assert.NotContains(t, reqs[0].Body, "sekret")
+	if assert.Len(t, body["destinations"], 1) {
+		dest := body["destinations"].([]any)[0].(map[string]any)
+		assert.Equal(t, "dest", dest["destination"])
+		assert.Equal(t, "the-backend", dest["backend"])
+		assert.Equal(t, "success", dest["status"])
+		assert.Equal(t, "abc123", dest["result"].(map[string]any)["id"])
+	}
+}
+
+func TestBackupSendsMatchingWebhooks(t *testing.T) {
+	srv, received := newWebhookReceiver(t)
+	runtimeDir := t.TempDir()
+	l := holdJobLock(t, runtimeDir)
+	defer l.Release()
+	svc := backupService{}
+	cfg := lockTestConfig(runtimeDir, nil)
+	cfg.MainConfig.StateDir = t.TempDir()
+	cfg.MainConfig.Notify = &config.NotifyV1{
+		Webhooks: map[string]config.WebhookV1{
+			"on-success": {URL: srv.URL + "/success", On: []string{config.NotifyOnSuccess}},
+			"on-failure": {
+				URL:    srv.URL + "/failure",
+				Method: "PUT",
+				On:     []string{config.NotifyOnFailure},
+				Body:   `{"text": {{ json (printf "%s failed: %s" .Job (index .Errors 0)) }}}`,
+			},
+			"other-job": {URL: srv.URL + "/other", Jobs: []string{"other-job"}},
+		},
+	}
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.Error(t, err)
+
+	reqs := received()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "PUT", reqs[0].Method)
+	assert.Equal(t, "/failure", reqs[0].Path)
+	assert.Regexp(t, `^\{"text": "my-job failed: job my-job is already running: .*"\}$`, reqs[0].Body)
+}
+
+func TestBackupWebhookRetry(t *testing.T) {
+	srv, received := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	sleeps := []time.Duration{}
+	svc := backupService{sleep: func(d time.Duration) { sleeps = append(sleeps, d) }}
+	cfg := lockTestConfig(t.TempDir(), nil)
+	cfg.MainConfig.StateDir = t.TempDir()
+	cfg.MainConfig.Notify = &config.NotifyV1{
+		Webhooks: map[string]config.WebhookV1{
+			"hook": {
+				URL:   srv.URL,
+				Retry: &config.RetryV1{Attempts: 3, InitialDelay: time.Second},
+			},
+		},
+	}
+
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+
+	assert.Len(t, received(), 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+}
+
+func TestBackupWebhookNoRetryOnClientError(t *testing.T) {
+	srv, received := newWebhookReceiver(t, http.StatusBadRequest)
+	svc := backupService{sleep: func(d time.Duration) {}}
+	cfg := lockTestConfig(t.TempDir(), nil)
+	cfg.MainConfig.StateDir = t.TempDir()
+	cfg.MainConfig.Notify = &config.NotifyV1{
+		Webhooks: map[string]config.WebhookV1{
+			"hook": {
+				URL:   srv.URL,
+				Retry: &config.RetryV1{Attempts: 3},
+			},
+		},
+	}
+
+	// The webhook failing doesn't fail the backup
+	_, err := svc.Backup(context.Background(), cfg, "my-job")
+	require.NoError(t, err)
+	assert.Len(t, received(), 1)
+}
+
+func TestNewNotificationDataSplitsErrors(t *testing.T) {
+	data := newNotificationData(
+		config.Config{},
+		&JobResult{Job: "my-job"},
+		errors.Join(errors.New("one"), errors.New("two")),
+	)
+	assert.Equal(t, "failure", data.Status)
+	assert.Equal(t, []string{"one", "two"}, data.Errors)
+}
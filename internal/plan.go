@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+)
+
+type (
+	// BackupPlan describes what backing up a job would do without doing it.
+	BackupPlan struct {
+		Job          string               `yaml:"job"`
+		Recipe       string               `yaml:"recipe"`
+		Paths        []string             `yaml:"paths"`
+		Exclude      []string             `yaml:"exclude,omitempty"`
+		Hooks        []PlannedHook        `yaml:"hooks,omitempty"`
+		Destinations []PlannedDestination `yaml:"destinations"`
+	}
+	PlannedHook struct {
+		Name    string `yaml:"name"`
+		When    string `yaml:"when"`
+		Shell   string `yaml:"shell"`
+		Command string `yaml:"command"`
+		Timeout string `yaml:"timeout,omitempty"`
+	}
+	PlannedDestination struct {
+		Destination string         `yaml:"destination"`
+		Backend     string         `yaml:"backend"`
+		Options     map[string]any `yaml:"options"`
+		// What the backend reported it would do. Filled in by the caller since
+		// it requires running the backend.
+		BackendPlan *proto.DryRunResponse `yaml:"backend-plan,omitempty"`
+		// Set when the backend could not report what it would do
+		Note string `yaml:"note,omitempty"`
+		// Request that would be sent to the backend
+		Request *proto.BackupRequest `yaml:"-"`
+	}
+)
+
+// PlanBackup resolves everything that goes into backing up the given job: the
+// recipe, the hooks that would run, and the merged options of every
+// destination.
+func PlanBackup(cfg config.Config, jobName string) (*BackupPlan, error) {
+	job, ok := cfg.MainConfig.Jobs[jobName]
+	if !ok {
+		return nil, fmt.Errorf("could not find a job named %s", jobName)
+	}
+	recipe, err := cfg.GetRecipeManifest(job.Recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &BackupPlan{
+		Job:          jobName,
+		Recipe:       recipe.Name,
+		Paths:        recipe.Paths,
+		Exclude:      recipe.Exclude,
+		Destinations: []PlannedDestination{},
+	}
+	for _, h := range []struct {
+		name string
+		when string
+		hook *config.HookV1
+	}{
+		{"before", "before backing up", recipe.Before},
+		{"after", "after backing up, even when it fails", recipe.After},
+		{"on-success", "when the job succeeds", job.OnSuccess},
+		{"on-failure", "when the job fails", job.OnFailure},
+	} {
+		if h.hook == nil {
+			continue
+		}
+		planned := PlannedHook{
+			Name:    h.name,
+			When:    h.when,
+			Shell:   h.hook.Shell,
+			Command: h.hook.Command,
+		}
+		if h.hook.Timeout > 0 {
+			planned.Timeout = h.hook.Timeout.String()
+		}
+		plan.Hooks = append(plan.Hooks, planned)
+	}
+
+	for _, destName := range job.BackupTo {
+		dest, ref, err := cfg.MainConfig.GetDestination(destName)
+		if err != nil {
+			return nil, err
+		}
+		plan.Destinations = append(plan.Destinations, PlannedDestination{
+			Destination: destName,
+			Backend:     dest.Backend,
+			Options:     dest.Options,
+			Request:     newBackupRequest(jobName, recipe, dest, ref),
+		})
+	}
+	return plan, nil
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/pkg/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanBackup(t *testing.T) {
+	plan, err := PlanBackup(config.Config{
+		Recipes: []config.RecipeManifestV1{{
+			Name:    "r",
+			Paths:   []string{"/a", "/b"},
+			Exclude: []string{"*.tmp"},
+			After:   &config.HookV1{Shell: "sh", Command: "echo after", Timeout: time.Minute},
+		}},
+		MainConfig: config.MainConfig{
+			Destinations: map[string]config.DestinationConfigV1{
+				"dest": {
+					Backend:  "the-backend",
+					Options:  map[string]any{"foo": "bar", "keep": 1},
+					Variants: map[string]map[string]any{"v": {"keep": 2}},
+				},
+			},
+			Jobs: map[string]config.JobConfigV1{
+				"my-job": {
+					Recipe:    "r",
+					BackupTo:  []string{"dest/v"},
+					OnFailure: &config.HookV1{Shell: "bash", Command: "echo failed"},
+				},
+			},
+		},
+	}, "my-job")
+	require.NoError(t, err)
+
+	options := map[string]any{"foo": "bar", "keep": 2}
+	assert.Equal(t, &BackupPlan{
+		Job:     "my-job",
+		Recipe:  "r",
+		Paths:   []string{"/a", "/b"},
+		Exclude: []string{"*.tmp"},
+		Hooks: []PlannedHook{
+			{
+				Name:    "after",
+				When:    "after backing up, even when it fails",
+				Shell:   "sh",
+				Command: "echo after",
+				Timeout: "1m0s",
+			},
+			{
+				Name:    "on-failure",
+				When:    "when the job fails",
+				Shell:   "bash",
+				Command: "echo failed",
+			},
+		},
+		Destinations: []PlannedDestination{{
+			Destination: "dest/v",
+			Backend:     "the-backend",
+			Options:     options,
+			Request: &proto.BackupRequest{
+				Paths:           []string{"/a", "/b"},
+				Exclude:         []string{"*.tmp"},
+				DestinationName: "dest",
+				VariantName:     "v",
+				JobName:         "my-job",
+				RawOptions:      options,
+			},
+		}},
+	}, plan)
+}
+
+func TestPlanBackupJobNotFound(t *testing.T) {
+	_, err := PlanBackup(config.Config{}, "nope")
+	assert.EqualError(t, err, "could not find a job named nope")
+}
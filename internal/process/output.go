@@ -0,0 +1,96 @@
+package process
+
+import (
+	"bytes"
+	"sync"
+)
+
+// tailSize is how much output Tail keeps. It's enough for the error messages
+// printed by most tools without filling notifications with progress output.
+const tailSize = 4 << 10
+
+// Tail keeps the end of what's written to it. It's used to report what a
+// command printed before it failed. It's safe to use as both the stdout and
+// the stderr of a command.
+type Tail struct {
+	lock      sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+func (t *Tail) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > tailSize {
+		t.buf = append([]byte{}, t.buf[len(t.buf)-tailSize:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the output kept so far. When older output was dropped, the
+// first line is dropped as well since it's likely cut in the middle.
+func (t *Tail) String() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	res := t.buf
+	if t.truncated {
+		if i := bytes.IndexByte(res, '\n'); i >= 0 {
+			res = res[i+1:]
+		}
+	}
+	return string(bytes.TrimSpace(res))
+}
+
+// OutputError is the error of a command along with the end of what it
+// printed. The output is not part of the error message.
+type OutputError struct {
+	Err    error
+	Output string
+}
+
+func (e *OutputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// WithOutput attaches the output kept by tail to err. err is returned as is
+// when it's nil or when the command printed nothing.
+func WithOutput(err error, tail *Tail) error {
+	if err == nil {
+		return nil
+	}
+	output := tail.String()
+	if output == "" {
+		return err
+	}
+	return &OutputError{Err: err, Output: output}
+}
+
+// Outputs returns the output attached to err and to all the errors it wraps.
+func Outputs(err error) []string {
+	res := []string{}
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if outputErr, ok := err.(*OutputError); ok {
+			res = append(res, outputErr.Output)
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		}
+	}
+	walk(err)
+	return res
+}
@@ -0,0 +1,213 @@
+// Package process runs child processes that can be stopped as a whole. Hooks
+// and backends often spawn processes of their own (ex: restic, rsync, occ) so
+// stopping only the direct child is not enough.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// gracePeriod is how long processes get to exit after SIGTERM before they're
+// sent SIGKILL.
+var gracePeriod = 10 * time.Second
+
+// TimeoutError is the cause of contexts created with WithTimeout once their
+// timeout expires.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+// WithTimeout is like context.WithTimeout except that a timeout of 0 means no
+// timeout and that the context's cause is a *TimeoutError once it expires.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, &TimeoutError{Timeout: timeout})
+}
+
+// SignalError is the cause of contexts created with WithSignals once one of
+// the signals is received.
+type SignalError struct {
+	Signal syscall.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("received signal %s", e.Signal)
+}
+
+// ExitCode follows the shell convention for processes killed by a signal.
+func (e *SignalError) ExitCode() int {
+	return 128 + int(e.Signal)
+}
+
+// exit is os.Exit. Tests replace it.
+var exit = os.Exit
+
+// WithSignals returns a context that gets canceled when one of the given
+// signals is received. Its cause is then a *SignalError. Once the first signal
+// is received, child processes get a chance to exit cleanly. A second signal
+// means that we're done waiting: every process group started by Command gets
+// SIGKILL and we exit right away.
+func WithSignals(ctx context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopped := make(chan struct{})
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		select {
+		case sig := <-ch:
+			cancel(&SignalError{Signal: sig.(syscall.Signal)})
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case sig := <-ch:
+			killGroups()
+			exit((&SignalError{Signal: sig.(syscall.Signal)}).ExitCode())
+		case <-stopped:
+		}
+	}()
+	return ctx, sync.OnceFunc(func() {
+		signal.Stop(ch)
+		cancel(context.Canceled)
+		close(stopped)
+	})
+}
+
+// WithCleanupTimeout returns a context that is not canceled along with ctx.
+// Instead, it gets canceled timeout after ctx is done. This is meant for
+// cleanup work that needs to happen even when the operation is canceled.
+func WithCleanupTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	cleanupCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(timeout, func() {
+			cancel(&TimeoutError{Timeout: timeout})
+		})
+		context.AfterFunc(cleanupCtx, func() { timer.Stop() })
+	})
+	return cleanupCtx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// groups holds the process groups of the commands that are running so that
+// they can all be killed at once.
+var groups = struct {
+	sync.Mutex
+	pgids map[int]bool
+}{pgids: map[int]bool{}}
+
+// killGroups sends SIGKILL to the process groups of all running commands.
+func killGroups() {
+	groups.Lock()
+	defer groups.Unlock()
+	for pgid := range groups.pgids {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+}
+
+// Cmd is a command started by Command. It's used like exec.Cmd.
+type Cmd struct {
+	*exec.Cmd
+	lock sync.Mutex
+	// Sends SIGKILL to the process group once the grace period is over
+	killTimer *time.Timer
+}
+
+// Command is like exec.CommandContext except that the command runs in its own
+// process group. When ctx is done, the whole group gets SIGTERM and then
+// SIGKILL if it's still around after a grace period. When ctx was canceled
+// because of a signal (see WithSignals), that signal is forwarded instead of
+// SIGTERM.
+func Command(ctx context.Context, name string, args ...string) *Cmd {
+	cmd := &Cmd{Cmd: exec.CommandContext(ctx, name, args...)}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		sig := syscall.SIGTERM
+		var signalErr *SignalError
+		if errors.As(context.Cause(ctx), &signalErr) {
+			sig = signalErr.Signal
+		}
+		pgid := cmd.Process.Pid
+		err := syscall.Kill(-pgid, sig)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		} else if err != nil {
+			return err
+		}
+		cmd.lock.Lock()
+		defer cmd.lock.Unlock()
+		cmd.killTimer = time.AfterFunc(gracePeriod, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return nil
+	}
+	// Stop waiting on stdout & stderr if something outside of the process group
+	// holds on to them.
+	cmd.WaitDelay = gracePeriod + time.Second
+	return cmd
+}
+
+// Start is like exec.Cmd.Start except that it keeps track of the process
+// group until Wait returns.
+func (c *Cmd) Start() error {
+	err := c.Cmd.Start()
+	if err != nil {
+		return err
+	}
+	groups.Lock()
+	defer groups.Unlock()
+	groups.pgids[c.Process.Pid] = true
+	return nil
+}
+
+// Run is like exec.Cmd.Run. See Wait.
+func (c *Cmd) Run() error {
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Wait is like exec.Cmd.Wait except that it stops the pending SIGKILL once the
+// process is done. Past that point, the process group id can be reused by
+// unrelated processes.
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	groups.Lock()
+	delete(groups.pgids, c.Process.Pid)
+	groups.Unlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.killTimer != nil {
+		c.killTimer.Stop()
+	}
+	return err
+}
+
+// Err explains why a command failed when it was stopped because ctx is done.
+// Other errors are returned as is.
+func Err(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", context.Cause(ctx), err)
+}
@@ -0,0 +1,224 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandNoTimeout(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), 0)
+	defer cancel()
+	err := Err(ctx, Command(ctx, "sh", "-c", "exit 0").Run())
+	assert.NoError(t, err)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := Err(ctx, Command(ctx, "sleep", "60").Run())
+	assert.Less(t, time.Since(start), 5*time.Second)
+	var timeoutErr *TimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+	}
+	assert.ErrorContains(t, err, "timed out after 100ms")
+}
+
+func TestCommandTerminatesProcessGroup(t *testing.T) {
+	pidFile := path.Join(t.TempDir(), "pid")
+	ctx, cancel := WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	// The child is in the background so only a group wide signal reaches it
+	err := Err(ctx, Command(ctx, "sh", "-c", "sleep 60 & echo $! > "+pidFile+"; wait").Run())
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+
+	raw, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid := strings.TrimSpace(string(raw))
+	assert.Eventually(t, func() bool {
+		return !processAlive(t, pid)
+	}, 5*time.Second, 50*time.Millisecond, "background process %s is still alive", pid)
+}
+
+func TestCommandKillAfterGracePeriod(t *testing.T) {
+	original := gracePeriod
+	gracePeriod = 200 * time.Millisecond
+	t.Cleanup(func() { gracePeriod = original })
+
+	ctx, cancel := WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	cmd := Command(ctx, "sh", "-c", "trap '' TERM; while true; do sleep 0.1; done")
+	err := Err(ctx, cmd.Run())
+	assert.Less(t, time.Since(start), 5*time.Second)
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	if assert.NotNil(t, cmd.ProcessState) {
+		status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		assert.Equal(t, syscall.SIGKILL, status.Signal())
+	}
+}
+
+func TestErrCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Err(ctx, Command(ctx, "sleep", "60").Run())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWithSignals(t *testing.T) {
+	ctx, stop := WithSignals(context.Background(), syscall.SIGUSR1)
+	defer stop()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not canceled by the signal")
+	}
+	var signalErr *SignalError
+	if assert.ErrorAs(t, context.Cause(ctx), &signalErr) {
+		assert.Equal(t, syscall.SIGUSR1, signalErr.Signal)
+		assert.Equal(t, 128+int(syscall.SIGUSR1), signalErr.ExitCode())
+	}
+}
+
+func TestWithSignalsSecondSignalKills(t *testing.T) {
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	ctx, stop := WithSignals(context.Background(), syscall.SIGUSR1)
+	defer stop()
+	// Stands in for a hook that takes a long time to clean up
+	cmd := Command(context.Background(), "sleep", "10")
+	require.NoError(t, cmd.Start())
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	<-ctx.Done()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case code := <-exited:
+		assert.Equal(t, 128+int(syscall.SIGUSR1), code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second signal did not exit")
+	}
+	assert.EqualError(t, cmd.Wait(), "signal: killed")
+}
+
+func TestCommandForwardsSignal(t *testing.T) {
+	outFile := path.Join(t.TempDir(), "out")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cmd := Command(ctx, "sh", "-c",
+		"trap 'echo INT > "+outFile+"; exit 3' INT; while true; do sleep 0.1; done")
+	require.NoError(t, cmd.Start())
+	time.Sleep(100 * time.Millisecond)
+	cancel(&SignalError{Signal: syscall.SIGINT})
+	err := Err(ctx, cmd.Wait())
+	var signalErr *SignalError
+	assert.ErrorAs(t, err, &signalErr)
+	raw, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Equal(t, "INT\n", string(raw))
+}
+
+func TestWithCleanupTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cleanupCtx, stop := WithCleanupTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+
+	cancel()
+	assert.NoError(t, cleanupCtx.Err(), "cleanup context should outlive its parent")
+	select {
+	case <-cleanupCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup context was not canceled after the timeout")
+	}
+	var timeoutErr *TimeoutError
+	if assert.ErrorAs(t, context.Cause(cleanupCtx), &timeoutErr) {
+		assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+	}
+}
+
+func TestWithCleanupTimeoutParentNotDone(t *testing.T) {
+	cleanupCtx, stop := WithCleanupTimeout(context.Background(), time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, cleanupCtx.Err())
+	stop()
+	assert.ErrorIs(t, cleanupCtx.Err(), context.Canceled)
+}
+
+func processAlive(t *testing.T, pid string) bool {
+	t.Helper()
+	_, err := os.Stat(path.Join("/proc", pid))
+	if err != nil {
+		return false
+	}
+	// Zombies are dead for our purposes
+	stat, err := os.ReadFile(path.Join("/proc", pid, "stat"))
+	return err == nil && !strings.Contains(string(stat), ") Z ")
+}
+
+func TestTail(t *testing.T) {
+	tail := &Tail{}
+	_, _ = tail.Write([]byte("hello\n"))
+	_, _ = tail.Write([]byte("world\n"))
+	assert.Equal(t, "hello\nworld", tail.String())
+
+	// Once it's over the limit, old output gets dropped up to the next line
+	_, _ = tail.Write([]byte(strings.Repeat("x", tailSize-3) + "\nend\n"))
+	assert.Equal(t, "end", tail.String())
+}
+
+func TestWithOutput(t *testing.T) {
+	assert.NoError(t, WithOutput(nil, &Tail{}))
+
+	err := errors.New("oops")
+	assert.Same(t, err, WithOutput(err, &Tail{}))
+
+	tail := &Tail{}
+	_, _ = tail.Write([]byte("some output\n"))
+	res := WithOutput(err, tail)
+	assert.EqualError(t, res, "oops")
+	assert.ErrorIs(t, res, err)
+	assert.Equal(t, []string{"some output"}, Outputs(res))
+}
+
+func TestOutputs(t *testing.T) {
+	err := errors.Join(
+		fmt.Errorf("hook failed: %w", &OutputError{Err: errors.New("a"), Output: "one"}),
+		errors.New("b"),
+		&OutputError{Err: errors.New("c"), Output: "two"},
+	)
+	assert.Equal(t, []string{"one", "two"}, Outputs(err))
+	assert.Equal(t, []string{}, Outputs(errors.New("nope")))
+}
+
+func TestCommandStopsKillAfterWait(t *testing.T) {
+	original := gracePeriod
+	gracePeriod = 5 * time.Second
+	t.Cleanup(func() { gracePeriod = original })
+
+	ctx, cancel := WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cmd := Command(ctx, "sleep", "60")
+	err := Err(ctx, cmd.Run())
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	// sleep exits on SIGTERM, SIGKILL must not be sent to its process group
+	// id after that.
+	if assert.NotNil(t, cmd.killTimer) {
+		assert.False(t, cmd.killTimer.Stop(), "SIGKILL is still pending")
+	}
+}
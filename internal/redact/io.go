@@ -3,6 +3,7 @@ package redact
 import (
 	"io"
 	"os"
+	"sync"
 
 	"golang.org/x/text/transform"
 )
@@ -21,10 +22,58 @@ func init() {
 		panic(err)
 	}
 	redactTransformer = r
-	Stdout = transform.NewWriter(os.Stdout, r)
-	Stderr = transform.NewWriter(os.Stderr, r)
+	Stdout = newSyncWriteCloser(transform.NewWriter(os.Stdout, r))
+	Stderr = newSyncWriteCloser(transform.NewWriter(os.Stderr, r))
+}
+
+// syncWriteCloser serializes writes to the underlying writer. transform.Writer
+// is not safe for concurrent use.
+type syncWriteCloser struct {
+	lock sync.Mutex
+	w    WriteCloser
+}
+
+func newSyncWriteCloser(w WriteCloser) *syncWriteCloser {
+	return &syncWriteCloser{w: w}
+}
+
+func (s *syncWriteCloser) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *syncWriteCloser) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.w.Close()
+}
+
+// NewStdout returns a writer that redacts secrets before writing to stdout.
+// It's meant for the output of a single child process. Child processes running
+// in parallel can't share a writer since the output of one would break up a
+// secret that the other is in the middle of writing. Close flushes what's held
+// back while looking for secrets.
+func NewStdout() WriteCloser {
+	return transform.NewWriter(os.Stdout, redactTransformer)
+}
+
+// NewStderr is like NewStdout but it writes to stderr.
+func NewStderr() WriteCloser {
+	return transform.NewWriter(os.Stderr, redactTransformer)
 }
 
 func AddSecrets(secrets ...string) error {
 	return redactTransformer.AddSecrets(secrets...)
 }
+
+// String redacts secrets from s. This is for text that doesn't go through
+// Stdout or Stderr (ex: error messages stored in the run history).
+func String(s string) string {
+	res, _, err := transform.String(redactTransformer, s)
+	if err != nil {
+		// Better to lose the text than to leak a secret
+		return REPLACE
+	}
+	return res
+}
@@ -180,3 +180,24 @@ func TestOverlapSecretAcrossWrites(t *testing.T) {
 		})
 	}
 }
+
+func TestWritersPerProcess(t *testing.T) {
+	r, err := NewTransformer("supersecret")
+	require.NoError(t, err)
+
+	// Like NewStdout, both processes share the transformer and the final
+	// output but each has its own writer.
+	out := bytes.Buffer{}
+	a := transform.NewWriter(&out, r)
+	b := transform.NewWriter(&out, r)
+	_, err = a.Write([]byte("a: super"))
+	require.NoError(t, err)
+	_, err = b.Write([]byte("b: hello\n"))
+	require.NoError(t, err)
+	_, err = a.Write([]byte("secret\n"))
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+
+	assert.Equal(t, "a: b: hello\n***\n", out.String())
+}
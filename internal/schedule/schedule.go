@@ -0,0 +1,284 @@
+// Package schedule parses cron expressions and finds the times that match them.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression. It supports the standard 5 fields
+// (minute, hour, day of month, month, day of week) with lists, ranges, steps
+// and names (ex: jan, mon) along with the @hourly, @daily, @weekly, @monthly
+// and @yearly shorthands.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Like cron, when both the day of month and the day of week are
+	// restricted, a day matches if either of them match.
+	domRestricted, dowRestricted bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also sunday
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (*Schedule, error) {
+	expanded := strings.TrimSpace(expr)
+	if strings.HasPrefix(expanded, "@") {
+		var ok bool
+		expanded, ok = shorthands[expanded]
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule %q: unknown shorthand", expr)
+		}
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf(
+			"invalid schedule %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d",
+			expr, len(parts),
+		)
+	}
+
+	var s Schedule
+	var err error
+	for _, f := range []struct {
+		field *field
+		raw   string
+		bits  *uint64
+	}{
+		{&minuteField, parts[0], &s.minute},
+		{&hourField, parts[1], &s.hour},
+		{&domField, parts[2], &s.dom},
+		{&monthField, parts[3], &s.month},
+		{&dowField, parts[4], &s.dow},
+	} {
+		*f.bits, err = f.field.parse(f.raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 << 0
+	}
+	s.domRestricted = !strings.HasPrefix(parts[2], "*")
+	s.dowRestricted = !strings.HasPrefix(parts[4], "*")
+	return &s, nil
+}
+
+func (f *field) parse(raw string) (uint64, error) {
+	var res uint64
+	for _, item := range strings.Split(raw, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, f.name)
+			}
+		}
+
+		var start, end int
+		if rangePart == "*" {
+			start, end = f.min, f.max
+		} else {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			start, err = f.value(startPart)
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				end, err = f.value(endPart)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// Like cron, 5/10 means from 5 to the end in steps of 10
+				end = f.max
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			res |= 1 << v
+		}
+	}
+	return res, nil
+}
+
+func (f *field) value(raw string) (int, error) {
+	if v, ok := f.names[strings.ToLower(raw)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", raw, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %d", f.name, f.min, f.max, v)
+	}
+	return v, nil
+}
+
+// Next returns the first time matching the schedule that is strictly after the
+// given time. Times are matched in the location of the given time. It returns
+// the zero time if nothing matches in the next 5 years (ex: February 30th).
+func (s *Schedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = nextHour(t)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// nextHour returns the start of the hour after t. It adds to t instead of
+// building a new date so that it moves forward across DST changes.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// forward returns next unless DST made it land before t. This happens when the
+// start of a day falls in a DST gap.
+func forward(t time.Time, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return nextHour(t)
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Days of the week in the order systemd uses, starting on monday
+var weekdayNames = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// OnCalendar returns the systemd calendar event (as used by OnCalendar= in
+// timer units) matching the same times as the schedule. Schedules that
+// restrict both the day of month and the day of week can't be converted since
+// systemd requires both to match while cron requires either of them to match.
+func (s *Schedule) OnCalendar() (string, error) {
+	if s.domRestricted && s.dowRestricted {
+		return "", errors.New(
+			"schedules restricting both the day of month and the day of week can't be converted to systemd calendar events",
+		)
+	}
+	format := func(v int) string { return fmt.Sprintf("%02d", v) }
+	dow := s.dow &^ (1 << 7)
+	res := ""
+	if dowField.isRestricted(dow) {
+		// Move sunday from the start of the week to the end
+		mondayFirst := dow >> 1
+		if dow&1 != 0 {
+			mondayFirst |= 1 << 6
+		}
+		res = calendarValues(mondayFirst, 0, 6, func(v int) string { return weekdayNames[v] }) + " "
+	}
+	res += fmt.Sprintf("*-%s-%s %s:%s:00",
+		monthField.calendarValues(s.month, format),
+		domField.calendarValues(s.dom, format),
+		hourField.calendarValues(s.hour, format),
+		minuteField.calendarValues(s.minute, format),
+	)
+	return res, nil
+}
+
+func (f *field) isRestricted(bits uint64) bool {
+	max := f.max
+	if f == &dowField {
+		max = 6
+	}
+	for v := f.min; v <= max; v++ {
+		if bits&(1<<v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *field) calendarValues(bits uint64, format func(int) string) string {
+	if !f.isRestricted(bits) {
+		return "*"
+	}
+	return calendarValues(bits, f.min, f.max, format)
+}
+
+// calendarValues lists the values in bits. Runs of 3 or more consecutive
+// values are written as ranges.
+func calendarValues(bits uint64, min int, max int, format func(int) string) string {
+	parts := []string{}
+	for v := min; v <= max; v++ {
+		if bits&(1<<v) == 0 {
+			continue
+		}
+		end := v
+		for end+1 <= max && bits&(1<<(end+1)) != 0 {
+			end++
+		}
+		switch {
+		case end-v >= 2:
+			parts = append(parts, format(v)+".."+format(end))
+		case end > v:
+			parts = append(parts, format(v), format(end))
+		default:
+			parts = append(parts, format(v))
+		}
+		v = end
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,126 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// A wednesday
+	base := time.Date(2025, 1, 15, 10, 30, 45, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"30 * * * *", time.Date(2025, 1, 15, 11, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 22 * * 1-5", time.Date(2025, 1, 15, 22, 0, 0, 0, time.UTC)},
+		{"0 4 * * sat,sun", time.Date(2025, 1, 18, 4, 0, 0, 0, time.UTC)},
+		{"0 4 * * 7", time.Date(2025, 1, 19, 4, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 9 * * *", time.Date(2025, 1, 16, 9, 5, 0, 0, time.UTC)},
+		{"0 12 1-3,20 * *", time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week
+		{"0 0 20 * fri", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			s, err := Parse(tc.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, s.Next(base))
+		})
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("IST", 5*60*60+30*60)
+	s, err := Parse("0 3 * * *")
+	require.NoError(t, err)
+	next := s.Next(time.Date(2025, 1, 15, 10, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2025, 1, 16, 3, 0, 0, 0, loc), next)
+}
+
+func TestNextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	s, err := Parse("30 2 * * *")
+	require.NoError(t, err)
+	// 2:30 doesn't exist on March 9th 2025. The next run happens on the 10th.
+	next := s.Next(time.Date(2025, 3, 9, 0, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2025, 3, 10, 2, 30, 0, 0, loc), next)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		err  string
+	}{
+		{"", "expected 5 fields"},
+		{"* * * *", "expected 5 fields"},
+		{"* * * * * *", "expected 5 fields"},
+		{"@often", "unknown shorthand"},
+		{"60 * * * *", "minute must be between 0 and 59, got 60"},
+		{"* 24 * * *", "hour must be between 0 and 23, got 24"},
+		{"* * 0 * *", "day of month must be between 1 and 31, got 0"},
+		{"* * * 13 *", "month must be between 1 and 12, got 13"},
+		{"* * * * 8", "day of week must be between 0 and 7, got 8"},
+		{"*/0 * * * *", `invalid step "0" in minute field`},
+		{"5-1 * * * *", `invalid range "5-1" in minute field`},
+		{"* * * foo *", `invalid value "foo" in month field`},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestOnCalendar(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "*-*-* *:*:00"},
+		{"0 3 * * *", "*-*-* 03:00:00"},
+		{"@daily", "*-*-* 00:00:00"},
+		{"@hourly", "*-*-* *:00:00"},
+		{"@weekly", "Sun *-*-* 00:00:00"},
+		{"@monthly", "*-*-01 00:00:00"},
+		{"@yearly", "*-01-01 00:00:00"},
+		{"*/15 * * * *", "*-*-* *:00,15,30,45:00"},
+		{"30 22 * * 1-5", "Mon..Fri *-*-* 22:30:00"},
+		{"0 4 * * sat,sun", "Sat,Sun *-*-* 04:00:00"},
+		{"0 4 * * 0,5,6", "Fri..Sun *-*-* 04:00:00"},
+		{"0 4 * * 7", "Sun *-*-* 04:00:00"},
+		{"0 0 1,2,15 jan-mar *", "*-01..03-01,02,15 00:00:00"},
+		{"0 9-17/4 * * *", "*-*-* 09,13,17:00:00"},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			s, err := Parse(tc.expr)
+			require.NoError(t, err)
+			res, err := s.OnCalendar()
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestOnCalendarDayOfMonthAndWeek(t *testing.T) {
+	s, err := Parse("0 0 1 * mon")
+	require.NoError(t, err)
+	_, err = s.OnCalendar()
+	assert.ErrorContains(t, err, "can't be converted to systemd calendar events")
+}
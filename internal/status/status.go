@@ -0,0 +1,154 @@
+// Package status keeps track of the latest outcome of every job so that users
+// and monitoring systems can tell whether backups are up to date.
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/atomicfile"
+	"github.com/dotboris/standard-backups/internal/history"
+)
+
+type Verdict string
+
+const (
+	// The last run succeeded and it's recent enough
+	VerdictOk Verdict = "OK"
+	// The last successful run is older than the job's max age
+	VerdictStale Verdict = "STALE"
+	// The last run failed
+	VerdictFailing Verdict = "FAILING"
+	// The job never ran and it has no max age. There's nothing wrong with it
+	// yet.
+	VerdictPending Verdict = "PENDING"
+	// The status of the job could not be determined (ex: its status file is
+	// corrupt)
+	VerdictUnknown Verdict = "UNKNOWN"
+)
+
+// ExitCode returns the exit code that monitoring systems like Nagios and
+// Icinga expect for the verdict.
+func (v Verdict) ExitCode() int {
+	switch v {
+	case VerdictOk, VerdictPending:
+		return 0
+	case VerdictStale:
+		return 1 // WARNING
+	case VerdictFailing:
+		return 2 // CRITICAL
+	default:
+		return 3 // UNKNOWN
+	}
+}
+
+// Worse returns true if v is more severe than other. Verdicts are ranked like
+// their exit codes so that the worst verdict of many jobs gives the exit code.
+func (v Verdict) Worse(other Verdict) bool {
+	return v.ExitCode() > other.ExitCode()
+}
+
+// JobStatus is the latest outcome of a job. Unlike the history, there's only
+// ever one of these per job.
+type JobStatus struct {
+	Job           string     `json:"job"`
+	LastRun       time.Time  `json:"last_run"`
+	LastRunStatus string     `json:"last_run_status"`
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+	LastFailure   *time.Time `json:"last_failure,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// Verdict tells if the job is healthy at the given time. maxAge is how old the
+// last successful run can be. 0 means that there's no limit.
+func (s *JobStatus) Verdict(now time.Time, maxAge time.Duration) Verdict {
+	switch {
+	case s == nil || s.LastSuccess == nil && s.LastFailure == nil:
+		if maxAge > 0 {
+			return VerdictStale
+		}
+		return VerdictPending
+	case s.LastRunStatus == history.StatusFailure:
+		return VerdictFailing
+	case maxAge > 0 && now.Sub(*s.LastSuccess) > maxAge:
+		return VerdictStale
+	default:
+		return VerdictOk
+	}
+}
+
+// Store keeps one small JSON file per job.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store that keeps its files in a subdirectory of the given
+// state directory.
+func NewStore(stateDir string) *Store {
+	return &Store{dir: path.Join(stateDir, "status")}
+}
+
+func (s *Store) path(job string) string {
+	return path.Join(s.dir, job+".json")
+}
+
+// Load returns the status of the given job. It returns nil if the job never
+// ran.
+func (s *Store) Load(job string) (*JobStatus, error) {
+	raw, err := os.ReadFile(s.path(job))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var res JobStatus
+	err = json.Unmarshal(raw, &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse status of job %s: %w", job, err)
+	}
+	return &res, nil
+}
+
+// Update updates the status of a job with the outcome of a run. Skipped runs
+// say nothing about the health of a job so they are ignored.
+func (s *Store) Update(rec history.Record) error {
+	if rec.Status == history.StatusSkipped {
+		return nil
+	}
+	res, err := s.Load(rec.Job)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		res = &JobStatus{Job: rec.Job}
+	}
+	res.LastRun = rec.StartTime
+	res.LastRunStatus = rec.Status
+	endTime := rec.EndTime
+	if rec.Status == history.StatusSuccess {
+		res.LastSuccess = &endTime
+	} else {
+		res.LastFailure = &endTime
+		res.LastError = rec.Error
+	}
+	return s.write(res)
+}
+
+// write replaces the status file atomically so that readers never see a
+// partially written file.
+func (s *Store) write(js *JobStatus) error {
+	raw, err := json.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.path(js.Job), 0o644, func(w io.Writer) error {
+		_, err := w.Write(raw)
+		return err
+	})
+}
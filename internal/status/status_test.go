@@ -0,0 +1,133 @@
+package status
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreUpdate(t *testing.T) {
+	stateDir := t.TempDir()
+	store := NewStore(stateDir)
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	res, err := store.Load("my-job")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+
+	require.NoError(t, store.Update(history.Record{
+		Job:       "my-job",
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Status:    history.StatusSuccess,
+	}))
+	require.NoError(t, store.Update(history.Record{
+		Job:       "my-job",
+		StartTime: start.Add(time.Hour),
+		EndTime:   start.Add(time.Hour + time.Minute),
+		Status:    history.StatusFailure,
+		Error:     "oops",
+	}))
+	// Skipped runs are ignored
+	require.NoError(t, store.Update(history.Record{
+		Job:       "my-job",
+		StartTime: start.Add(2 * time.Hour),
+		EndTime:   start.Add(2 * time.Hour),
+		Status:    history.StatusSkipped,
+	}))
+
+	res, err = store.Load("my-job")
+	require.NoError(t, err)
+	lastSuccess := start.Add(time.Minute)
+	lastFailure := start.Add(time.Hour + time.Minute)
+	assert.Equal(t, &JobStatus{
+		Job:           "my-job",
+		LastRun:       start.Add(time.Hour),
+		LastRunStatus: history.StatusFailure,
+		LastSuccess:   &lastSuccess,
+		LastFailure:   &lastFailure,
+		LastError:     "oops",
+	}, res)
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(path.Join(stateDir, "status"))
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "my-job.json", entries[0].Name())
+	}
+}
+
+func TestStoreLoadMalformed(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "status"), 0o755))
+	require.NoError(t, os.WriteFile(path.Join(stateDir, "status", "my-job.json"), []byte("{"), 0o644))
+	_, err := NewStore(stateDir).Load("my-job")
+	assert.ErrorContains(t, err, "failed to parse status of job my-job")
+}
+
+func TestVerdict(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	hourAgo := now.Add(-time.Hour)
+	dayAgo := now.Add(-24 * time.Hour)
+	for _, tc := range []struct {
+		name   string
+		status *JobStatus
+		maxAge time.Duration
+		want   Verdict
+	}{
+		{"never ran", nil, 0, VerdictPending},
+		{"never ran with max age", nil, time.Hour, VerdictStale},
+		{
+			"succeeded",
+			&JobStatus{LastRunStatus: history.StatusSuccess, LastSuccess: &hourAgo},
+			0,
+			VerdictOk,
+		},
+		{
+			"succeeded recently",
+			&JobStatus{LastRunStatus: history.StatusSuccess, LastSuccess: &hourAgo},
+			2 * time.Hour,
+			VerdictOk,
+		},
+		{
+			"succeeded too long ago",
+			&JobStatus{LastRunStatus: history.StatusSuccess, LastSuccess: &dayAgo},
+			2 * time.Hour,
+			VerdictStale,
+		},
+		{
+			"failed",
+			&JobStatus{
+				LastRunStatus: history.StatusFailure,
+				LastSuccess:   &dayAgo,
+				LastFailure:   &hourAgo,
+			},
+			48 * time.Hour,
+			VerdictFailing,
+		},
+		{
+			"never succeeded",
+			&JobStatus{LastRunStatus: history.StatusFailure, LastFailure: &hourAgo},
+			0,
+			VerdictFailing,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.status.Verdict(now, tc.maxAge))
+		})
+	}
+}
+
+func TestVerdictWorse(t *testing.T) {
+	assert.True(t, VerdictUnknown.Worse(VerdictFailing))
+	assert.True(t, VerdictFailing.Worse(VerdictStale))
+	assert.True(t, VerdictStale.Worse(VerdictPending))
+	assert.False(t, VerdictPending.Worse(VerdictOk))
+	assert.False(t, VerdictOk.Worse(VerdictOk))
+	assert.False(t, VerdictStale.Worse(VerdictFailing))
+}
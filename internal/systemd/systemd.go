@@ -0,0 +1,170 @@
+// Package systemd generates systemd service and timer units that run backup
+// jobs on their schedule.
+package systemd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/schedule"
+)
+
+type (
+	Options struct {
+		// Path of the standard-backups binary
+		Bin string
+		// Path of the main config. Passed to every command with --config.
+		ConfigPath string
+		// Spread the start of timers over a random delay of up to this long. 0
+		// disables it.
+		RandomizedDelay time.Duration
+		// Restrict what the backup can do to the system (ex: make /usr read
+		// only)
+		Hardening bool
+	}
+	Unit struct {
+		// File name of the unit (ex: standard-backups-my-job.service)
+		Name    string
+		Content string
+	}
+)
+
+// hardeningDirectives still let backups read the whole system and write to
+// local destinations outside of /usr, /boot and /efi. NoNewPrivileges is left
+// out since it breaks hooks that use sudo or other setuid programs.
+var hardeningDirectives = []string{
+	"ProtectSystem=full",
+	"PrivateTmp=true",
+	"ProtectKernelTunables=true",
+	"ProtectKernelModules=true",
+	"ProtectControlGroups=true",
+}
+
+// Units returns a service and a timer unit for every job that has a schedule.
+// The output only depends on the config and the options so that it can be
+// regenerated over and over again.
+func Units(mc config.MainConfig, opts Options) ([]Unit, error) {
+	jobNames := make([]string, 0, len(mc.Jobs))
+	for name := range mc.Jobs {
+		jobNames = append(jobNames, name)
+	}
+	sort.Strings(jobNames)
+
+	res := []Unit{}
+	for _, jobName := range jobNames {
+		job := mc.Jobs[jobName]
+		if job.Schedule == "" {
+			slog.Info("skipping job without a schedule", slog.String("job", jobName))
+			continue
+		}
+		s, err := schedule.Parse(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s has an invalid schedule: %w", jobName, err)
+		}
+		onCalendar, err := s.OnCalendar()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the schedule of job %s: %w", jobName, err)
+		}
+
+		baseName := "standard-backups-" + jobName
+		res = append(res,
+			Unit{Name: baseName + ".service", Content: serviceUnit(jobName, opts)},
+			Unit{Name: baseName + ".timer", Content: timerUnit(jobName, job.Schedule, onCalendar, opts)},
+		)
+	}
+	return res, nil
+}
+
+// StaleUnits returns the paths of the units in dir that were generated by
+// standard-backups but are not in units anymore (ex: their job was removed).
+// Units that were not generated are left out since they belong to someone else.
+func StaleUnits(dir string, units []Unit) ([]string, error) {
+	current := map[string]bool{}
+	for _, unit := range units {
+		current[unit.Name] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || current[name] || !strings.HasPrefix(name, "standard-backups-") {
+			continue
+		}
+		if !strings.HasSuffix(name, ".service") && !strings.HasSuffix(name, ".timer") {
+			continue
+		}
+		p := path.Join(dir, name)
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(string(content), header) {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func serviceUnit(jobName string, opts Options) string {
+	w := &strings.Builder{}
+	writeHeader(w)
+	fmt.Fprintf(w, "[Unit]\n")
+	fmt.Fprintf(w, "Description=standard-backups job %s\n", jobName)
+	fmt.Fprintf(w, "Wants=network-online.target\n")
+	fmt.Fprintf(w, "After=network-online.target\n")
+	fmt.Fprintf(w, "\n[Service]\n")
+	fmt.Fprintf(w, "Type=oneshot\n")
+	fmt.Fprintf(w, "ExecStart=%s --config %s backup %s\n",
+		quote(opts.Bin), quote(opts.ConfigPath), jobName)
+	if opts.Hardening {
+		for _, directive := range hardeningDirectives {
+			fmt.Fprintln(w, directive)
+		}
+	}
+	return w.String()
+}
+
+func timerUnit(jobName string, cronSchedule string, onCalendar string, opts Options) string {
+	w := &strings.Builder{}
+	writeHeader(w)
+	fmt.Fprintf(w, "[Unit]\n")
+	fmt.Fprintf(w, "Description=Run standard-backups job %s on schedule\n", jobName)
+	fmt.Fprintf(w, "\n[Timer]\n")
+	fmt.Fprintf(w, "# schedule: %s\n", cronSchedule)
+	fmt.Fprintf(w, "OnCalendar=%s\n", onCalendar)
+	// Run missed backups (ex: the machine was off) as soon as possible
+	fmt.Fprintf(w, "Persistent=true\n")
+	if opts.RandomizedDelay > 0 {
+		fmt.Fprintf(w, "RandomizedDelaySec=%d\n", int64(opts.RandomizedDelay.Seconds()))
+	}
+	fmt.Fprintf(w, "\n[Install]\n")
+	fmt.Fprintf(w, "WantedBy=timers.target\n")
+	return w.String()
+}
+
+// header starts every generated unit. It's how generated units are told apart
+// from the ones written by hand.
+const header = "# Generated by standard-backups generate systemd. Changes will be overwritten.\n"
+
+func writeHeader(w *strings.Builder) {
+	w.WriteString(header)
+}
+
+// quote quotes arguments of ExecStart= that contain characters that systemd
+// would otherwise interpret.
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%")
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,140 @@
+package systemd
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnits(t *testing.T) {
+	mc := config.MainConfig{
+		Jobs: map[string]config.JobConfigV1{
+			"nightly": {Recipe: "r", Schedule: "30 2 * * *"},
+			"manual":  {Recipe: "r"},
+			"weekly":  {Recipe: "r", Schedule: "@weekly"},
+		},
+	}
+	units, err := Units(mc, Options{
+		Bin:             "/usr/bin/standard-backups",
+		ConfigPath:      "/etc/standard-backups/config.yaml",
+		RandomizedDelay: 10 * time.Minute,
+		Hardening:       true,
+	})
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, u := range units {
+		names = append(names, u.Name)
+	}
+	assert.Equal(t, []string{
+		"standard-backups-nightly.service",
+		"standard-backups-nightly.timer",
+		"standard-backups-weekly.service",
+		"standard-backups-weekly.timer",
+	}, names)
+
+	assert.Equal(t, testutils.Dedent(`
+		# Generated by standard-backups generate systemd. Changes will be overwritten.
+		[Unit]
+		Description=standard-backups job nightly
+		Wants=network-online.target
+		After=network-online.target
+
+		[Service]
+		Type=oneshot
+		ExecStart=/usr/bin/standard-backups --config /etc/standard-backups/config.yaml backup nightly
+		ProtectSystem=full
+		PrivateTmp=true
+		ProtectKernelTunables=true
+		ProtectKernelModules=true
+		ProtectControlGroups=true
+	`)+"\n", units[0].Content)
+	assert.Equal(t, testutils.Dedent(`
+		# Generated by standard-backups generate systemd. Changes will be overwritten.
+		[Unit]
+		Description=Run standard-backups job nightly on schedule
+
+		[Timer]
+		# schedule: 30 2 * * *
+		OnCalendar=*-*-* 02:30:00
+		Persistent=true
+		RandomizedDelaySec=600
+
+		[Install]
+		WantedBy=timers.target
+	`)+"\n", units[1].Content)
+	assert.Contains(t, units[3].Content, "OnCalendar=Sun *-*-* 00:00:00\n")
+
+	// Same output every time
+	again, err := Units(mc, Options{
+		Bin:             "/usr/bin/standard-backups",
+		ConfigPath:      "/etc/standard-backups/config.yaml",
+		RandomizedDelay: 10 * time.Minute,
+		Hardening:       true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, units, again)
+}
+
+func TestUnitsWithoutOptions(t *testing.T) {
+	units, err := Units(config.MainConfig{
+		Jobs: map[string]config.JobConfigV1{
+			"my-job": {Recipe: "r", Schedule: "@daily"},
+		},
+	}, Options{Bin: "/opt/my backups/standard-backups", ConfigPath: "/etc/sb/config.yaml"})
+	require.NoError(t, err)
+	if assert.Len(t, units, 2) {
+		assert.Contains(t, units[0].Content,
+			"ExecStart=\"/opt/my backups/standard-backups\" --config /etc/sb/config.yaml backup my-job\n")
+		assert.NotContains(t, units[0].Content, "ProtectSystem")
+		assert.NotContains(t, units[1].Content, "RandomizedDelaySec")
+	}
+}
+
+func TestUnitsUnconvertibleSchedule(t *testing.T) {
+	_, err := Units(config.MainConfig{
+		Jobs: map[string]config.JobConfigV1{
+			"my-job": {Recipe: "r", Schedule: "0 0 1 * mon"},
+		},
+	}, Options{})
+	assert.ErrorContains(t, err, "failed to convert the schedule of job my-job")
+}
+
+func TestStaleUnits(t *testing.T) {
+	d := t.TempDir()
+	units, err := Units(config.MainConfig{
+		Jobs: map[string]config.JobConfigV1{
+			"kept":    {Recipe: "r", Schedule: "@daily"},
+			"removed": {Recipe: "r", Schedule: "@daily"},
+		},
+	}, Options{})
+	require.NoError(t, err)
+	for _, unit := range units {
+		err = os.WriteFile(path.Join(d, unit.Name), []byte(unit.Content), 0o644)
+		require.NoError(t, err)
+	}
+	// Written by hand, not ours to remove
+	err = os.WriteFile(path.Join(d, "standard-backups-custom.service"), []byte("[Unit]\n"), 0o644)
+	require.NoError(t, err)
+
+	res, err := StaleUnits(d, units[:2])
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			path.Join(d, "standard-backups-removed.service"),
+			path.Join(d, "standard-backups-removed.timer"),
+		}, res)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "/usr/bin/x", quote("/usr/bin/x"))
+	assert.Equal(t, `""`, quote(""))
+	assert.Equal(t, `"/a b/c"`, quote("/a b/c"))
+	assert.Equal(t, `"/a\"b/$$HOME/100%%"`, quote(`/a"b/$HOME/100%`))
+}
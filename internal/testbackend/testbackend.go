@@ -24,12 +24,22 @@ type BaseImpl struct {
 	Enable bool
 	Error  string
 }
+type BackupImpl struct {
+	BaseImpl
+	Progress []proto.ProgressEvent
+	Res      *proto.BackupResponse
+}
+type DryRunImpl struct {
+	BaseImpl
+	Res *proto.DryRunResponse
+}
 type ListBackupsImpl struct {
 	BaseImpl
 	Res *proto.ListBackupsResponse
 }
 type Impl struct {
-	Backup      BaseImpl
+	Backup      BackupImpl
+	DryRun      DryRunImpl
 	Exec        BaseImpl
 	ListBackups ListBackupsImpl
 	Restore     BaseImpl
@@ -66,6 +76,11 @@ func (b *TestBackend) Apply(cmd *exec.Cmd) {
 	)
 }
 
+// TraceDir is where the test backend records the requests it receives
+func (b *TestBackend) TraceDir() string {
+	return b.traceDir
+}
+
 func (b *TestBackend) RequireTrace(command string) []byte {
 	b.t.Helper()
 	p := path.Join(b.traceDir, fmt.Sprintf("%s.json", command))
@@ -8,7 +8,8 @@ import (
 )
 
 // BlockingBackend is standard-backups backend meant for testing. When invoked,
-// it blocks waiting for a signal from the testing process. All its actions are
+// it blocks waiting for a signal from the testing process. The capabilities
+// command is the exception, it answers right away. All its actions are
 // logged to a file so that they can be examined later on.
 type BlockingBackend struct {
 	Path     string
@@ -32,6 +33,10 @@ func NewBlockingBackend(t *testing.T, name string, logFile string) *BlockingBack
 				echo "$name: $*"
 				echo "$name: $*" >> $log_file
 			}
+			if [[ "$STANDARD_BACKUPS_COMMAND" == capabilities ]]; then
+				echo '{"capabilities": ["backup"]}'
+				exit
+			fi
 			log started
 			log waiting
 			while true; do
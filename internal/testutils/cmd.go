@@ -30,6 +30,8 @@ func ApplyExampleConfig(t *testing.T, cmd *exec.Cmd) {
 	}
 	cmd.Env = append(cmd.Env,
 		fmt.Sprintf("XDG_DATA_DIRS=%s/examples/config/share", root),
+		fmt.Sprintf("XDG_RUNTIME_DIR=%s", t.TempDir()),
+		fmt.Sprintf("XDG_STATE_HOME=%s", t.TempDir()),
 		// Erase existing value to avoid host env contamination
 		"XDG_CONFIG_DIRS=",
 	)
@@ -13,6 +13,8 @@ type TestConfig struct {
 	DataDir     string
 	BackendsDir string
 	RecipesDir  string
+	RuntimeDir  string
+	StateDir    string
 	t           *testing.T
 }
 
@@ -42,6 +44,8 @@ func NewTestConfig(t *testing.T) *TestConfig {
 		DataDir:     dataDir,
 		BackendsDir: backendsDir,
 		RecipesDir:  recipesDir,
+		RuntimeDir:  t.TempDir(),
+		StateDir:    t.TempDir(),
 		t:           t,
 	}
 }
@@ -53,6 +57,8 @@ func (tc *TestConfig) Apply(cmd *exec.Cmd) {
 	}
 	cmd.Env = append(cmd.Env,
 		fmt.Sprintf("XDG_DATA_DIRS=%s", tc.DataDir),
+		fmt.Sprintf("XDG_RUNTIME_DIR=%s", tc.RuntimeDir),
+		fmt.Sprintf("XDG_STATE_HOME=%s", tc.StateDir),
 		// Erase existing value to avoid host env contamination
 		"XDG_CONFIG_DIRS=",
 	)
@@ -1,12 +1,22 @@
 package proto
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/dotboris/standard-backups/internal/process"
 )
 
 type (
-	BackupFunc    func(req *BackupRequest) error
+	BackupFunc    func(req *BackupRequest) (*BackupResponse, error)
 	BackupRequest struct {
 		Paths           []string
 		Exclude         []string
@@ -14,6 +24,21 @@ type (
 		VariantName     string
 		JobName         string
 		RawOptions      map[string]any
+
+		events io.Writer
+	}
+	// BackupResponse describes the backup that a backend just produced. All
+	// fields are optional since not every backend can report every detail.
+	BackupResponse struct {
+		Id              string         `json:"id"`
+		Time            string         `json:"time"`
+		BytesAdded      int64          `json:"bytes_added"`     // New data written to the destination
+		BytesProcessed  int64          `json:"bytes_processed"` // Size of everything that was backed up
+		FilesNew        int64          `json:"files_new"`
+		FilesChanged    int64          `json:"files_changed"`
+		FilesUnmodified int64          `json:"files_unmodified"`
+		FilesProcessed  int64          `json:"files_processed"`
+		Extra           map[string]any `json:"extra"`
 	}
 )
 
@@ -36,6 +61,10 @@ func NewBackupRequestFromEnv() (*BackupRequest, error) {
 	if err != nil {
 		return nil, err
 	}
+	events, err := openEventsFromEnv()
+	if err != nil {
+		return nil, err
+	}
 	return &BackupRequest{
 		Paths:           paths,
 		Exclude:         exclude,
@@ -43,6 +72,7 @@ func NewBackupRequestFromEnv() (*BackupRequest, error) {
 		VariantName:     variantName,
 		JobName:         jobName,
 		RawOptions:      options,
+		events:          events,
 	}, nil
 }
 
@@ -69,13 +99,97 @@ func (br *BackupRequest) ToEnv() ([]string, error) {
 	}, nil
 }
 
-func (bc *BackendClient) Backup(req *BackupRequest) error {
+// Backup asks the backend to perform a backup. When onProgress is not nil, it
+// gets called with every progress event that the backend reports.
+//
+// The response is nil when the backend doesn't report one. This happens with
+// backends that don't use BackendImpl (ex: hand written scripts). Anything
+// such backends print on stdout is passed along as is.
+func (bc *BackendClient) Backup(
+	ctx context.Context,
+	req *BackupRequest,
+	onProgress ProgressFunc,
+) (*BackupResponse, error) {
 	env, err := req.ToEnv()
+	if err != nil {
+		return nil, err
+	}
+	cmd, closeOutput := bc.cmd(ctx, "backup", env)
+	defer closeOutput()
+	// stdout is held until we know if it's a backup response. Otherwise, it
+	// goes to the backend's own redacting writer.
+	redactedStdout := cmd.Stdout
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	stderr := &process.Tail{}
+	cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	if onProgress == nil {
+		err = cmd.Run()
+	} else {
+		err = runWithProgress(cmd, onProgress)
+	}
+	if err != nil {
+		_, _ = stdout.WriteTo(redactedStdout)
+		return nil, process.WithOutput(process.Err(ctx, err), stderr)
+	}
+	return bc.parseBackupResponse(stdout.Bytes(), redactedStdout)
+}
+
+// parseBackupResponse parses what the backend printed on stdout. When that's
+// not a backup response, it's passed along to out.
+func (bc *BackendClient) parseBackupResponse(stdout []byte, out io.Writer) (*BackupResponse, error) {
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil, nil
+	}
+	var res BackupResponse
+	err := json.Unmarshal(stdout, &res)
+	if err != nil {
+		slog.Debug("backend did not report a backup response",
+			slog.String("backend", bc.Manifest.Name),
+			slog.Any("error", err))
+		_, err := out.Write(stdout)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// eventsWaitDelay is how long runWithProgress keeps reading progress events
+// after the backend exits.
+var eventsWaitDelay = time.Second
+
+func runWithProgress(cmd *process.Cmd, onProgress ProgressFunc) error {
+	eventsReader, eventsWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create progress events pipe: %w", err)
+	}
+	defer func() { _ = eventsReader.Close() }()
+	cmd.ExtraFiles = []*os.File{eventsWriter}
+	cmd.Env = append(cmd.Env, toEnvStr(EVENTS_FD_ENV, strconv.Itoa(eventsFd)))
+
+	err = cmd.Start()
+	// The backend has its own copy now, ours would keep the pipe open forever
+	_ = eventsWriter.Close()
 	if err != nil {
 		return err
 	}
-	cmd := bc.cmd("backup", env)
-	err = cmd.Run()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		readProgressEvents(eventsReader, onProgress)
+	}()
+	err = cmd.Wait()
+	// Processes started by the backend can inherit the pipe and keep it open
+	// long after the backend is done. Give them a moment to send their last
+	// events and then stop listening.
+	timer := time.NewTimer(eventsWaitDelay)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		_ = eventsReader.Close()
+		<-done
+	}
 	return err
 }
 
@@ -87,5 +201,13 @@ func (bi *BackendImpl) backup() error {
 	if err != nil {
 		return err
 	}
-	return bi.Backup(req)
+	res, err := bi.Backup(req)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(res)
 }
@@ -0,0 +1,32 @@
+package proto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupProgressPipeHeldByBackgroundProcess(t *testing.T) {
+	original := eventsWaitDelay
+	eventsWaitDelay = 100 * time.Millisecond
+	t.Cleanup(func() { eventsWaitDelay = original })
+
+	// The background process inherits the events pipe and keeps it open after
+	// the backend exits.
+	bc := scriptBackendClient(t, `
+		echo '{"phase":"backup"}' >&3
+		sleep 5 >/dev/null 2>&1 &
+	`)
+	events := []ProgressEvent{}
+	start := time.Now()
+	res, err := bc.Backup(context.Background(), &BackupRequest{}, func(ev ProgressEvent) {
+		events = append(events, ev)
+	})
+	assert.Less(t, time.Since(start), 3*time.Second)
+	if assert.NoError(t, err) {
+		assert.Nil(t, res)
+		assert.Equal(t, []ProgressEvent{{Phase: "backup"}}, events)
+	}
+}
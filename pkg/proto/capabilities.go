@@ -0,0 +1,123 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/dotboris/standard-backups/internal/redact"
+)
+
+type (
+	Capability           string
+	CapabilitiesResponse struct {
+		Capabilities []Capability `json:"capabilities"`
+	}
+)
+
+const (
+	CapabilityBackup      Capability = "backup"
+	CapabilityDryRun      Capability = "dry-run"
+	CapabilityExec        Capability = "exec"
+	CapabilityListBackups Capability = "list-backups"
+	CapabilityRestore     Capability = "restore"
+)
+
+// Description returns a human readable description of what a backend with this
+// capability is able to do. It's meant to be used in error messages.
+func (c Capability) Description() string {
+	switch c {
+	case CapabilityBackup:
+		return "perform backups"
+	case CapabilityDryRun:
+		return "describe backups without performing them"
+	case CapabilityExec:
+		return "execute commands"
+	case CapabilityListBackups:
+		return "list backups"
+	case CapabilityRestore:
+		return "restore backups"
+	default:
+		return string(c)
+	}
+}
+
+func (r *CapabilitiesResponse) Has(c Capability) bool {
+	return slices.Contains(r.Capabilities, c)
+}
+
+// Capabilities asks the backend which commands it handles. Backends that don't
+// implement the capabilities command (ex: hand written scripts) make this fail.
+// Callers should treat such errors as "unknown" and not as "unsupported".
+//
+// Those backends usually complain about the unknown command on stderr. That's
+// expected so what the backend prints on stderr is only logged for debugging.
+func (bc *BackendClient) Capabilities(ctx context.Context) (*CapabilitiesResponse, error) {
+	cmd, closeOutput := bc.cmd(ctx, "capabilities", nil)
+	defer closeOutput()
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		slog.Debug("backend printed on stderr while querying capabilities",
+			slog.String("backend", bc.Manifest.Name),
+			slog.String("stderr", redact.String(stderr.String())))
+	}
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to query capabilities of backend %s: %w",
+			bc.Manifest.Name,
+			err,
+		)
+	}
+
+	res, err := parseCapabilitiesResponse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to parse capabilities of backend %s: %w",
+			bc.Manifest.Name,
+			err,
+		)
+	}
+	return res, nil
+}
+
+func parseCapabilitiesResponse(stdout []byte) (*CapabilitiesResponse, error) {
+	var res CapabilitiesResponse
+	err := json.Unmarshal(stdout, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (bi *BackendImpl) capabilities() *CapabilitiesResponse {
+	res := &CapabilitiesResponse{Capabilities: []Capability{}}
+	if bi.Backup != nil {
+		res.Capabilities = append(res.Capabilities, CapabilityBackup)
+	}
+	if bi.DryRun != nil {
+		res.Capabilities = append(res.Capabilities, CapabilityDryRun)
+	}
+	if bi.Exec != nil {
+		res.Capabilities = append(res.Capabilities, CapabilityExec)
+	}
+	if bi.ListBackups != nil {
+		res.Capabilities = append(res.Capabilities, CapabilityListBackups)
+	}
+	if bi.Restore != nil {
+		res.Capabilities = append(res.Capabilities, CapabilityRestore)
+	}
+	return res
+}
+
+func (bi *BackendImpl) writeCapabilities() error {
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(bi.capabilities())
+}
@@ -0,0 +1,93 @@
+package proto
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCapabilitiesResponse(t *testing.T) {
+	res, err := parseCapabilitiesResponse([]byte(`{"capabilities":["backup","restore"]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Capability{CapabilityBackup, CapabilityRestore}, res.Capabilities)
+	}
+}
+
+func TestParseCapabilitiesResponseEmpty(t *testing.T) {
+	res, err := parseCapabilitiesResponse([]byte(`{"capabilities":[]}`))
+	if assert.NoError(t, err) {
+		assert.Empty(t, res.Capabilities)
+		assert.False(t, res.Has(CapabilityBackup))
+	}
+}
+
+func TestParseCapabilitiesResponseInvalid(t *testing.T) {
+	_, err := parseCapabilitiesResponse([]byte("Usage: my-backend [command]\n"))
+	assert.Error(t, err)
+}
+
+func TestCapabilitiesHas(t *testing.T) {
+	res := &CapabilitiesResponse{
+		Capabilities: []Capability{CapabilityBackup, CapabilityListBackups},
+	}
+	assert.True(t, res.Has(CapabilityBackup))
+	assert.True(t, res.Has(CapabilityListBackups))
+	assert.False(t, res.Has(CapabilityRestore))
+	assert.False(t, res.Has(CapabilityExec))
+}
+
+func TestBackendImplCapabilities(t *testing.T) {
+	bi := &BackendImpl{
+		Backup:  func(req *BackupRequest) (*BackupResponse, error) { return nil, nil },
+		Restore: func(req *RestoreRequest) error { return nil },
+	}
+	assert.Equal(t, &CapabilitiesResponse{
+		Capabilities: []Capability{CapabilityBackup, CapabilityRestore},
+	}, bi.capabilities())
+}
+
+func scriptBackendClient(t *testing.T, script string) *BackendClient {
+	bin := path.Join(t.TempDir(), "backend")
+	err := os.WriteFile(bin, []byte("#!/bin/sh\n"+script), 0o755)
+	require.NoError(t, err)
+	return &BackendClient{
+		Manifest: config.BackendManifestV1{Name: "script", Bin: bin},
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	bc := scriptBackendClient(t, `echo '{"capabilities":["backup"]}'`)
+	res, err := bc.Capabilities(context.Background())
+	if assert.NoError(t, err) {
+		assert.True(t, res.Has(CapabilityBackup))
+		assert.False(t, res.Has(CapabilityRestore))
+	}
+}
+
+func TestCapabilitiesUnsupportedIsQuiet(t *testing.T) {
+	bc := scriptBackendClient(t, `
+		echo "Error: unknown command capabilities" >&2
+		exit 1
+	`)
+
+	// Capture what would be printed on stderr
+	stderrPath := path.Join(t.TempDir(), "stderr")
+	stderr, err := os.Create(stderrPath)
+	require.NoError(t, err)
+	realStderr := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = realStderr }()
+
+	_, err = bc.Capabilities(context.Background())
+	assert.ErrorContains(t, err, "failed to query capabilities of backend script")
+
+	require.NoError(t, stderr.Close())
+	printed, err := os.ReadFile(stderrPath)
+	require.NoError(t, err)
+	assert.Empty(t, string(printed))
+}
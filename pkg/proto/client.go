@@ -1,10 +1,11 @@
 package proto
 
 import (
+	"context"
 	"os"
-	"os/exec"
 
 	"github.com/dotboris/standard-backups/internal/config"
+	"github.com/dotboris/standard-backups/internal/process"
 	"github.com/dotboris/standard-backups/internal/redact"
 )
 
@@ -23,14 +24,26 @@ func NewBackendClient(cfg config.Config, name string) (*BackendClient, error) {
 	}, nil
 }
 
-func (bc *BackendClient) cmd(command string, env []string) *exec.Cmd {
-	cmd := exec.Command(bc.Manifest.Bin)
+// cmd prepares a call to the backend. Its output goes through its own redacting
+// writers since backends can run in parallel. The returned function flushes
+// them and must be called once the command is done.
+func (bc *BackendClient) cmd(
+	ctx context.Context,
+	command string,
+	env []string,
+) (*process.Cmd, func()) {
+	cmd := process.Command(ctx, bc.Manifest.Bin)
 	cmd.Env = append(
 		os.Environ(),
 		toEnvStr(COMMAND_ENV, command),
 	)
 	cmd.Env = append(cmd.Env, env...)
-	cmd.Stdout = redact.Stdout
-	cmd.Stderr = redact.Stderr
-	return cmd
+	stdout := redact.NewStdout()
+	stderr := redact.NewStderr()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd, func() {
+		_ = stdout.Close()
+		_ = stderr.Close()
+	}
 }
@@ -0,0 +1,63 @@
+package proto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/dotboris/standard-backups/internal/process"
+)
+
+type (
+	// DryRunFunc describes what the backend would do if it were asked to
+	// perform the given backup. It must not change anything in the destination.
+	DryRunFunc     func(req *BackupRequest) (*DryRunResponse, error)
+	DryRunResponse struct {
+		// Human readable steps that the backend would take (ex: commands it would
+		// run)
+		Actions []string       `json:"actions" yaml:"actions"`
+		Extra   map[string]any `json:"extra"   yaml:"extra,omitempty"`
+	}
+)
+
+// DryRun asks the backend what it would do to perform the given backup without
+// actually performing it.
+func (bc *BackendClient) DryRun(ctx context.Context, req *BackupRequest) (*DryRunResponse, error) {
+	env, err := req.ToEnv()
+	if err != nil {
+		return nil, err
+	}
+	cmd, closeOutput := bc.cmd(ctx, "dry-run", env)
+	defer closeOutput()
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	err = cmd.Run()
+	if err != nil {
+		return nil, process.Err(ctx, err)
+	}
+
+	var res DryRunResponse
+	err = json.Unmarshal(stdout.Bytes(), &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (bi *BackendImpl) dryRun() error {
+	if bi.DryRun == nil {
+		return errors.New("unhandled command dry-run")
+	}
+	req, err := NewBackupRequestFromEnv()
+	if err != nil {
+		return err
+	}
+	res, err := bi.DryRun(req)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(res)
+}
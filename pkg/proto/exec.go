@@ -1,8 +1,11 @@
 package proto
 
 import (
+	"context"
 	"errors"
 	"os"
+
+	"github.com/dotboris/standard-backups/internal/process"
 )
 
 type (
@@ -51,14 +54,15 @@ func (r *ExecRequest) ToEnv() ([]string, error) {
 	}, nil
 }
 
-func (bc *BackendClient) Exec(req *ExecRequest) error {
+func (bc *BackendClient) Exec(ctx context.Context, req *ExecRequest) error {
 	env, err := req.ToEnv()
 	if err != nil {
 		return err
 	}
-	cmd := bc.cmd("exec", env)
+	cmd, closeOutput := bc.cmd(ctx, "exec", env)
+	defer closeOutput()
 	err = cmd.Run()
-	return err
+	return process.Err(ctx, err)
 }
 
 func (bi *BackendImpl) exec() error {
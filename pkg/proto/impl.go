@@ -7,6 +7,7 @@ import (
 
 type BackendImpl struct {
 	Backup      BackupFunc
+	DryRun      DryRunFunc
 	Exec        ExecFunc
 	ListBackups ListBackupsFunc
 	Restore     RestoreFunc
@@ -18,8 +19,12 @@ func (bi *BackendImpl) execute() error {
 		return err
 	}
 	switch command {
+	case "capabilities":
+		return bi.writeCapabilities()
 	case "backup":
 		return bi.backup()
+	case "dry-run":
+		return bi.dryRun()
 	case "exec":
 		return bi.exec()
 	case "list-backups":
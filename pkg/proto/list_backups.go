@@ -2,9 +2,12 @@ package proto
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
+
+	"github.com/dotboris/standard-backups/internal/process"
 )
 
 type (
@@ -57,17 +60,21 @@ func (lbr *ListBackupsRequest) ToEnv() ([]string, error) {
 	}, nil
 }
 
-func (bc *BackendClient) ListBackups(req *ListBackupsRequest) (*ListBackupsResponse, error) {
+func (bc *BackendClient) ListBackups(
+	ctx context.Context,
+	req *ListBackupsRequest,
+) (*ListBackupsResponse, error) {
 	env, err := req.ToEnv()
 	if err != nil {
 		return nil, err
 	}
-	cmd := bc.cmd("list-backups", env)
+	cmd, closeOutput := bc.cmd(ctx, "list-backups", env)
+	defer closeOutput()
 	stdout := bytes.NewBuffer(nil)
 	cmd.Stdout = stdout
 	err = cmd.Run()
 	if err != nil {
-		return nil, err
+		return nil, process.Err(ctx, err)
 	}
 
 	var res ListBackupsResponse
@@ -0,0 +1,80 @@
+package proto
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+type (
+	// ProgressEvent describes how far along a backend is in a long running
+	// operation. Backends send these to the orchestrator as newline-delimited
+	// JSON on the file descriptor given in EVENTS_FD_ENV. All fields are
+	// optional. Zero values mean that the backend doesn't know.
+	ProgressEvent struct {
+		// Backend specific name of what the backend is currently doing (ex:
+		// init, backup, forget)
+		Phase            string  `json:"phase,omitempty"`
+		PercentDone      float64 `json:"percent_done,omitempty"` // Between 0 and 1
+		FilesDone        int64   `json:"files_done,omitempty"`
+		TotalFiles       int64   `json:"total_files,omitempty"`
+		BytesDone        int64   `json:"bytes_done,omitempty"`
+		TotalBytes       int64   `json:"total_bytes,omitempty"`
+		SecondsRemaining int64   `json:"seconds_remaining,omitempty"` // ETA
+	}
+	ProgressFunc func(ev ProgressEvent)
+)
+
+// eventsFd is the file descriptor that the events pipe is given to backends
+// as. The first 3 are stdin, stdout, and stderr.
+const eventsFd = 3
+
+// openEventsFromEnv opens the events pipe passed by the orchestrator. Returns
+// nil when the orchestrator isn't listening for events.
+func openEventsFromEnv() (io.Writer, error) {
+	raw, ok := os.LookupEnv(EVENTS_FD_ENV)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s=%s: %w", EVENTS_FD_ENV, raw, err)
+	}
+	// Processes spawned by the backend should not hold on to the pipe.
+	// Otherwise, the orchestrator could wait on them forever.
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), "events"), nil
+}
+
+// ReportProgress sends a progress event to the orchestrator. It does nothing
+// when the orchestrator isn't listening for events.
+func (br *BackupRequest) ReportProgress(ev ProgressEvent) error {
+	if br.events == nil {
+		return nil
+	}
+	return json.NewEncoder(br.events).Encode(ev)
+}
+
+// readProgressEvents reads events written by a backend until r is closed.
+func readProgressEvents(r io.Reader, onProgress ProgressFunc) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var ev ProgressEvent
+		err := json.Unmarshal(scanner.Bytes(), &ev)
+		if err != nil {
+			slog.Debug("ignoring malformed progress event",
+				slog.String("event", scanner.Text()),
+				slog.Any("error", err))
+			continue
+		}
+		onProgress(ev)
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Debug("stopped reading progress events", slog.Any("error", err))
+	}
+}
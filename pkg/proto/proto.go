@@ -11,6 +11,7 @@ const (
 	BACKUP_ID_ENV        = "STANDARD_BACKUPS_BACKUP_ID"
 	COMMAND_ENV          = "STANDARD_BACKUPS_COMMAND"
 	DESTINATION_NAME_ENV = "STANDARD_BACKUPS_DESTINATION_NAME"
+	EVENTS_FD_ENV        = "STANDARD_BACKUPS_EVENTS_FD"
 	EXCLUDE_ENV          = "STANDARD_BACKUPS_EXCLUDE"
 	JOB_NAME_ENV         = "STANDARD_BACKUPS_JOB_NAME"
 	OPTIONS_ENV          = "STANDARD_BACKUPS_OPTIONS"
@@ -1,8 +1,11 @@
 package proto
 
 import (
+	"context"
 	"errors"
 	"os"
+
+	"github.com/dotboris/standard-backups/internal/process"
 )
 
 type (
@@ -57,14 +60,15 @@ func (r *RestoreRequest) ToEnv() ([]string, error) {
 	}, nil
 }
 
-func (bc *BackendClient) Restore(req *RestoreRequest) error {
+func (bc *BackendClient) Restore(ctx context.Context, req *RestoreRequest) error {
 	env, err := req.ToEnv()
 	if err != nil {
 		return err
 	}
-	cmd := bc.cmd("restore", env)
+	cmd, closeOutput := bc.cmd(ctx, "restore", env)
+	defer closeOutput()
 	err = cmd.Run()
-	return err
+	return process.Err(ctx, err)
 }
 
 func (bi *BackendImpl) restore() error {